@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
 	"github.com/vivek-dodia/bookmarked-cli/internal/config"
 	"github.com/vivek-dodia/bookmarked-cli/internal/service"
 )
@@ -24,7 +26,10 @@ func main() {
 var rootCmd = &cobra.Command{
 	Use:   "bookmarked",
 	Short: "Sync Chrome bookmarks to GitHub automatically",
-	Long:  `A minimal background service that watches your Chrome bookmarks and syncs them to a GitHub repository.`,
+	Long:  `A minimal background service that watches your Chrome (or Chromium, Brave, Edge, Vivaldi, Opera) bookmarks and syncs them to a GitHub repository.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		config.SetConfigPath(cfgFile)
+	},
 }
 
 var initCmd = &cobra.Command{
@@ -35,6 +40,8 @@ var initCmd = &cobra.Command{
 	},
 }
 
+var syncOpts service.SyncOptions
+
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Manually trigger a sync",
@@ -45,7 +52,7 @@ var syncCmd = &cobra.Command{
 		}
 
 		svc := service.New(cfg)
-		return svc.SyncOnce()
+		return svc.SyncOnce(syncOpts)
 	},
 }
 
@@ -63,11 +70,184 @@ var startCmd = &cobra.Command{
 	},
 }
 
+var diffOpts service.DiffOptions
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [<rev>] [<rev>]",
+	Short: "Show bookmark changes between synced versions",
+	Long: `Show added, removed, renamed, moved and URL-changed bookmarks.
+
+With no revisions, the live Chrome bookmarks are compared to HEAD of the sync
+repository. With one revision, the live bookmarks are compared to that
+revision. With two, the revisions are compared to each other.`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		svc := service.New(cfg)
+		return svc.Diff(args, diffOpts, os.Stdout)
+	},
+}
+
+var restoreOpts service.RestoreOptions
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <rev>",
+	Short: "Restore bookmarks from a synced revision into the browser",
+	Long: `Restore the bookmarks synced at a revision of the repository (e.g. HEAD~3 or
+a commit hash) into the browser's bookmarks file. The current file is backed up
+first. The browser must be closed, since it overwrites the file on exit.
+
+Bookmarks left out by filters are kept. The repository only has URLs with
+secrets redacted: a bookmark keeps its current URL if the redacted version
+matches the restored one, but a bookmark that was deleted or whose URL changed
+since comes back with "REDACTED" in its URL.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		svc := service.New(cfg)
+		return svc.Restore(args[0], restoreOpts)
+	},
+}
+
+var exportOpts service.ExportOptions
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export bookmarks to another format",
+	Long: `Export the live browser bookmarks, or those synced at a revision with --rev,
+to another format:
+
+  json           the formatted Bookmarks.json synced to the repository
+  html           a Netscape bookmark file that every browser can import
+  markdown       one document with a heading per folder
+  markdown-tree  a directory with one markdown file per folder
+  csv            one row per bookmark: folder, title, url, domain, date_added,
+                 date_last_used, guid and depth
+  jsonl          the same fields as csv, one JSON object per line`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		svc := service.New(cfg)
+		return svc.Export(exportOpts, os.Stdout)
+	},
+}
+
+var importOpts service.ImportOptions
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import bookmarks from an HTML, JSON or CSV file into the browser",
+	Long: `Import bookmarks from a Netscape bookmark HTML file (exported by any browser),
+a Chrome-format Bookmarks JSON file, or a CSV file from 'bookmarked export'.
+The bookmarks are merged into a folder of the browser's bookmarks, skipping
+URLs that already exist. The current file is backed up first. The browser must
+be closed, since it overwrites the file on exit.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		svc := service.New(cfg)
+		return svc.Import(args[0], importOpts)
+	},
+}
+
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Look for secrets in the bookmarks and the repository history",
+	Long: `Look for secrets such as access tokens, signed URLs and JWTs in the live
+bookmarks, which are redacted before syncing, and in every version in the
+repository history, which were pushed before redaction was enabled. Exits with
+an error if the history contains secrets.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		svc := service.New(cfg)
+		return svc.Scan(os.Stdout)
+	},
+}
+
+var profilesBrowser string
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List browser profiles and which ones are synced",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Listing works before the config file is set up
+		cfg, err := config.Load()
+		if err != nil {
+			cfg = nil
+		}
+
+		browserID := profilesBrowser
+		if browserID == "" && cfg != nil && len(cfg.Browser) == 1 && !strings.EqualFold(cfg.Browser[0], config.AutoBrowser) {
+			browserID = cfg.Browser[0]
+		}
+		browser := bookmarks.Chrome
+		if browserID != "" {
+			if browser, err = bookmarks.FindBrowser(browserID); err != nil {
+				return err
+			}
+		}
+
+		return service.Profiles(browser, cfg, os.Stdout)
+	},
+}
+
+var machinesCmd = &cobra.Command{
+	Use:   "machines",
+	Short: "List the machines and profiles synced to the repository",
+	Long: `List every machine and browser profile syncing to the repository, from the
+index.json manifest of the remote branch, with the number of bookmarks and
+when the bookmarks of each last changed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		svc := service.New(cfg)
+		return svc.Machines(os.Stdout)
+	},
+}
+
+var browsersCmd = &cobra.Command{
+	Use:   "browsers",
+	Short: "List supported browsers and which ones have bookmarks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return service.Browsers(os.Stdout)
+	},
+}
+
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install as a background service",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return service.Install()
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		return service.Install(cfg)
 	},
 }
 
@@ -81,16 +261,48 @@ var uninstallCmd = &cobra.Command{
 
 var statusCmd = &cobra.Command{
 	Use:   "status",
-	Short: "Check service status",
+	Short: "Check service status and commits waiting to be pushed",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return service.Status()
+		// The service status is shown without a usable config file as well
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to load config, showing the service status only: %v\n", err)
+			cfg = nil
+		}
+
+		return service.Status(cfg)
 	},
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default: $BOOKMARKED_CONFIG or ~/.bookmarked/config.yaml)")
+	syncCmd.Flags().BoolVar(&syncOpts.DryRun, "dry-run", false, "Show what would be committed without cloning or writing anything")
+	syncCmd.Flags().BoolVar(&syncOpts.Explain, "explain", false, "List the bookmarks left out by filters and the rule for each")
+	diffCmd.Flags().BoolVar(&diffOpts.JSON, "json", false, "Output changes as JSON")
+	diffCmd.Flags().StringVar(&diffOpts.Profile, "profile", "", "Synced Chrome profile to compare")
+	restoreCmd.Flags().StringVar(&restoreOpts.Folder, "folder", "", "Only restore this folder, e.g. \"Bookmarks bar/Work\"")
+	restoreCmd.Flags().StringVar(&restoreOpts.Profile, "profile", "", "Synced Chrome profile to restore into")
+	restoreCmd.Flags().BoolVar(&restoreOpts.Force, "force", false, "Restore even if the browser is running")
+	exportCmd.Flags().StringVar(&exportOpts.Format, "format", bookmarks.OutputHTML, "Output format: json, html, markdown, markdown-tree, csv or jsonl")
+	exportCmd.Flags().StringVarP(&exportOpts.Output, "output", "o", "", "File to write (default: stdout), or directory for markdown-tree")
+	exportCmd.Flags().StringVar(&exportOpts.Profile, "profile", "", "Synced profile to export")
+	exportCmd.Flags().StringVar(&exportOpts.Rev, "rev", "", "Export the bookmarks synced at this revision, e.g. HEAD~1")
+	importCmd.Flags().StringVar(&importOpts.Into, "into", service.DefaultImportFolder, "Folder to import into")
+	importCmd.Flags().StringVar(&importOpts.Profile, "profile", "", "Synced profile to import into")
+	importCmd.Flags().BoolVar(&importOpts.Force, "force", false, "Import even if the browser is running")
+	profilesCmd.Flags().StringVar(&profilesBrowser, "browser", "", "Browser to list profiles of (default: from config, or chrome)")
+
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(syncCmd)
 	rootCmd.AddCommand(startCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(profilesCmd)
+	rootCmd.AddCommand(browsersCmd)
+	rootCmd.AddCommand(machinesCmd)
 	rootCmd.AddCommand(installCmd)
 	rootCmd.AddCommand(uninstallCmd)
 	rootCmd.AddCommand(statusCmd)
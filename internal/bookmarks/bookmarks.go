@@ -1,85 +1,100 @@
 package bookmarks
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 )
 
-// GetBookmarkPath returns the Chrome bookmarks file path for the current OS
-func GetBookmarkPath() (string, error) {
-	var bookmarkPath string
-
-	switch runtime.GOOS {
-	case "windows":
-		localAppData := os.Getenv("LOCALAPPDATA")
-		if localAppData == "" {
-			return "", fmt.Errorf("LOCALAPPDATA environment variable not set")
-		}
-		bookmarkPath = filepath.Join(localAppData, "Google", "Chrome", "User Data", "Default", "Bookmarks")
-
-	case "darwin":
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("failed to get home directory: %w", err)
-		}
-		bookmarkPath = filepath.Join(homeDir, "Library", "Application Support", "Google", "Chrome", "Default", "Bookmarks")
+// RepoFileName is the name of the formatted bookmarks file in the repository
+const RepoFileName = "Bookmarks.json"
 
-	case "linux":
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("failed to get home directory: %w", err)
-		}
-		bookmarkPath = filepath.Join(homeDir, ".config", "google-chrome", "Default", "Bookmarks")
+// DefaultProfile is the directory name of Chrome's default profile
+const DefaultProfile = "Default"
 
-	default:
-		return "", fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
-	}
-
-	// Check if file exists
-	if _, err := os.Stat(bookmarkPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("Chrome bookmarks file not found at: %s", bookmarkPath)
-	}
-
-	return bookmarkPath, nil
+// GetBookmarkPath returns the Chrome bookmarks file path for the current OS
+func GetBookmarkPath() (string, error) {
+	return Chrome.ProfileBookmarkPath(DefaultProfile)
 }
 
 // FormatBookmarks reads the Chrome bookmarks file and returns formatted JSON
 func FormatBookmarks(bookmarkPath string) ([]byte, error) {
-	// Read the raw bookmarks file
-	data, err := os.ReadFile(bookmarkPath)
+	f, err := Load(bookmarkPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read bookmarks file: %w", err)
-	}
-
-	// Parse JSON
-	var bookmarks interface{}
-	if err := json.Unmarshal(data, &bookmarks); err != nil {
-		return nil, fmt.Errorf("failed to parse bookmarks JSON: %w", err)
+		return nil, err
 	}
 
 	// Format with indentation for better diffs
-	formatted, err := json.MarshalIndent(bookmarks, "", "  ")
+	return f.Format()
+}
+
+// CopyToRepo copies and formats bookmarks to the target repository path,
+// along with any additional output formats such as "html"
+func CopyToRepo(bookmarkPath, repoPath string, outputs ...string) error {
+	f, err := Load(bookmarkPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to format bookmarks: %w", err)
+		return err
 	}
 
-	return formatted, nil
+	return WriteToRepo(f, repoPath, outputs...)
+}
+
+// WriteToRepo formats already loaded bookmarks into the target repository
+// path. Bookmarks.json is always written; outputs adds other formats.
+func WriteToRepo(f *File, repoPath string, outputs ...string) error {
+	rw := &RepoWriter{Outputs: outputs}
+	return rw.Write(f, repoPath)
+}
+
+// WriteFileFunc writes a file of the repository
+type WriteFileFunc func(path string, data []byte) error
+
+// WritePlainFile writes a file as is, creating its directory if needed
+func WritePlainFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// RepoWriter writes bookmarks and their other output formats into the
+// repository
+type RepoWriter struct {
+	Outputs   []string      // Formats besides Bookmarks.json, e.g. "html"
+	WriteFile WriteFileFunc // Writes each file, e.g. encrypted (default: WritePlainFile)
 }
 
-// CopyToRepo copies and formats bookmarks to the target repository path
-func CopyToRepo(bookmarkPath, repoPath string) error {
-	formatted, err := FormatBookmarks(bookmarkPath)
+// Write formats bookmarks into the target repository path
+func (rw *RepoWriter) Write(f *File, repoPath string) error {
+	formatted, err := f.Format()
 	if err != nil {
 		return err
 	}
 
-	targetPath := filepath.Join(repoPath, "Bookmarks.json")
-	if err := os.WriteFile(targetPath, formatted, 0644); err != nil {
+	targetPath := filepath.Join(repoPath, RepoFileName)
+	if err := rw.write(targetPath, formatted); err != nil {
 		return fmt.Errorf("failed to write formatted bookmarks: %w", err)
 	}
 
+	for _, output := range rw.Outputs {
+		exporter, err := FindExporter(output)
+		if err != nil {
+			return err
+		}
+		if exporter.Format == OutputJSON {
+			continue
+		}
+		if err := exporter.writeToRepo(f, repoPath, rw.write); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+func (rw *RepoWriter) write(path string, data []byte) error {
+	if rw.WriteFile == nil {
+		return WritePlainFile(path, data)
+	}
+	return rw.WriteFile(path, data)
+}
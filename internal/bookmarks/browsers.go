@@ -0,0 +1,245 @@
+package bookmarks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Format is the way a browser stores its bookmarks
+type Format int
+
+const (
+	// FormatChromium is Chrome's Bookmarks JSON file
+	FormatChromium Format = iota
+	// FormatFirefox is Firefox's places database, read from its backups
+	FormatFirefox
+)
+
+// Browser is a browser whose bookmarks can be synced
+type Browser struct {
+	ID     string // Used in the browser config setting, e.g. "brave"
+	Name   string // Display name, e.g. "Brave"
+	Format Format
+
+	// SingleProfile browsers keep the Bookmarks file directly in the user
+	// data directory instead of in profile subdirectories
+	SingleProfile bool
+
+	// Candidate user data directories per OS, the first existing one is used
+	windows        []string // Relative to %LOCALAPPDATA%
+	windowsRoaming []string // Relative to %APPDATA%
+	darwin         []string // Relative to ~/Library/Application Support
+	linux          []string // Relative to the home directory
+}
+
+// Chrome is Google Chrome, the default browser
+var Chrome = &Browser{
+	ID:      "chrome",
+	Name:    "Chrome",
+	windows: []string{`Google\Chrome\User Data`},
+	darwin:  []string{"Google/Chrome"},
+	linux:   []string{".config/google-chrome"},
+}
+
+// Browsers lists the supported browsers in auto-detection order
+var Browsers = []*Browser{
+	Chrome,
+	{
+		ID:      "chromium",
+		Name:    "Chromium",
+		windows: []string{`Chromium\User Data`},
+		darwin:  []string{"Chromium"},
+		linux:   []string{".config/chromium", "snap/chromium/common/chromium"},
+	},
+	{
+		ID:      "brave",
+		Name:    "Brave",
+		windows: []string{`BraveSoftware\Brave-Browser\User Data`},
+		darwin:  []string{"BraveSoftware/Brave-Browser"},
+		linux: []string{
+			".config/BraveSoftware/Brave-Browser",
+			".var/app/com.brave.Browser/config/BraveSoftware/Brave-Browser",
+			"snap/brave/current/.config/BraveSoftware/Brave-Browser",
+		},
+	},
+	{
+		ID:      "edge",
+		Name:    "Microsoft Edge",
+		windows: []string{`Microsoft\Edge\User Data`},
+		darwin:  []string{"Microsoft Edge"},
+		linux:   []string{".config/microsoft-edge"},
+	},
+	{
+		ID:      "vivaldi",
+		Name:    "Vivaldi",
+		windows: []string{`Vivaldi\User Data`},
+		darwin:  []string{"Vivaldi"},
+		linux:   []string{".config/vivaldi"},
+	},
+	{
+		ID:             "opera",
+		Name:           "Opera",
+		SingleProfile:  true,
+		windowsRoaming: []string{`Opera Software\Opera Stable`},
+		darwin:         []string{"com.operasoftware.Opera"},
+		linux:          []string{".config/opera"},
+	},
+	{
+		ID:             "firefox",
+		Name:           "Firefox",
+		Format:         FormatFirefox,
+		windowsRoaming: []string{`Mozilla\Firefox`},
+		darwin:         []string{"Firefox"},
+		linux: []string{
+			".mozilla/firefox",
+			"snap/firefox/common/.mozilla/firefox",
+			".var/app/org.mozilla.firefox/.mozilla/firefox",
+		},
+	},
+}
+
+// FindBrowser returns the browser with the given ID
+func FindBrowser(id string) (*Browser, error) {
+	ids := make([]string, len(Browsers))
+	for i, b := range Browsers {
+		if strings.EqualFold(b.ID, id) {
+			return b, nil
+		}
+		ids[i] = b.ID
+	}
+	return nil, fmt.Errorf("unsupported browser %q (supported: %s)", id, strings.Join(ids, ", "))
+}
+
+// DetectBrowsers returns the browsers that have at least one profile with a
+// Bookmarks file on this machine
+func DetectBrowsers() []*Browser {
+	var found []*Browser
+	for _, b := range Browsers {
+		profiles, err := b.ListProfiles()
+		if err != nil {
+			continue
+		}
+		for _, p := range profiles {
+			if p.HasBookmarks {
+				found = append(found, b)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// UserDataDir returns the browser's user data directory for the current OS
+func (b *Browser) UserDataDir() (string, error) {
+	var candidates []string
+
+	switch runtime.GOOS {
+	case "windows":
+		for _, env := range []struct {
+			name  string
+			paths []string
+		}{
+			{"LOCALAPPDATA", b.windows},
+			{"APPDATA", b.windowsRoaming},
+		} {
+			if len(env.paths) == 0 {
+				continue
+			}
+			base := os.Getenv(env.name)
+			if base == "" {
+				return "", fmt.Errorf("%s environment variable not set", env.name)
+			}
+			for _, p := range env.paths {
+				candidates = append(candidates, filepath.Join(base, p))
+			}
+		}
+
+	case "darwin":
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		for _, p := range b.darwin {
+			candidates = append(candidates, filepath.Join(homeDir, "Library", "Application Support", p))
+		}
+
+	case "linux":
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		for _, p := range b.linux {
+			candidates = append(candidates, filepath.Join(homeDir, filepath.FromSlash(p)))
+		}
+
+	default:
+		return "", fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	}
+
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("%s is not supported on %s", b.Name, runtime.GOOS)
+	}
+
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+	return candidates[0], nil
+}
+
+// DefaultProfile returns the profile the browser uses by default
+func (b *Browser) DefaultProfile() (Profile, error) {
+	profiles, err := b.ListProfiles()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	dir := DefaultProfile
+	if b.Format == FormatFirefox {
+		userDataDir, err := b.UserDataDir()
+		if err != nil {
+			return Profile{}, err
+		}
+		if dir, err = firefoxDefaultProfile(userDataDir); err != nil {
+			return Profile{}, err
+		}
+	}
+
+	for _, p := range profiles {
+		if p.Dir == dir || b.SingleProfile {
+			return p, nil
+		}
+	}
+
+	// Chromium browsers create the default profile before listing it
+	bookmarkPath, err := b.ProfileBookmarkPath(dir)
+	if err != nil {
+		return Profile{}, err
+	}
+	return Profile{Browser: b, Dir: dir, Name: dir, BookmarkPath: bookmarkPath, HasBookmarks: true}, nil
+}
+
+// ProfileBookmarkPath returns the bookmarks file path of a Chromium profile
+// directory (e.g. "Default" or "Profile 1")
+func (b *Browser) ProfileBookmarkPath(profile string) (string, error) {
+	userDataDir, err := b.UserDataDir()
+	if err != nil {
+		return "", err
+	}
+
+	bookmarkPath := filepath.Join(userDataDir, profile, "Bookmarks")
+	if b.SingleProfile {
+		bookmarkPath = filepath.Join(userDataDir, "Bookmarks")
+	}
+
+	// Check if file exists
+	if _, err := os.Stat(bookmarkPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("%s bookmarks file not found at: %s", b.Name, bookmarkPath)
+	}
+
+	return bookmarkPath, nil
+}
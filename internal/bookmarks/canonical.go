@@ -0,0 +1,160 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// VolatileFileName is the file volatile values are kept in when they are
+// separated rather than stripped
+const VolatileFileName = "Bookmarks.volatile.json"
+
+// structuralFields can't be volatile, the bookmark tree depends on them
+var structuralFields = map[string]bool{
+	"id": true, "guid": true, "name": true, "type": true, "url": true,
+	"children": true, "roots": true, "version": true,
+}
+
+// Volatile holds the values removed by Canonicalize, for the file itself
+// and for each node keyed "guid:<guid>" (or "id:<id>" without a GUID)
+type Volatile struct {
+	File  map[string]json.RawMessage            `json:"file,omitempty"`
+	Nodes map[string]map[string]json.RawMessage `json:"nodes,omitempty"`
+}
+
+// ValidateVolatileFields checks that fields can be removed from the file.
+// Fields are top level keys such as "checksum", node keys such as
+// "date_last_used", or "meta_info.<key>" for a single meta info entry.
+func ValidateVolatileFields(fields []string) error {
+	for _, field := range fields {
+		if structuralFields[field] || field == "" {
+			return fmt.Errorf("%q can't be a volatile field", field)
+		}
+	}
+	return nil
+}
+
+// Canonicalize returns a copy of the bookmarks without the volatile fields,
+// which change without any meaningful edit (e.g. date_last_used whenever a
+// bookmark is opened), together with the removed values. Formatting the
+// copy gives the same output as long as only volatile fields changed; keys
+// are always sorted.
+func (f *File) Canonicalize(fields []string) (*File, *Volatile, error) {
+	canonical, err := f.Clone()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	volatile := &Volatile{
+		File:  make(map[string]json.RawMessage),
+		Nodes: make(map[string]map[string]json.RawMessage),
+	}
+	for _, field := range fields {
+		if value, ok := canonical.takeVolatile(field); ok {
+			volatile.File[field] = value
+		}
+	}
+
+	canonical.Walk(func(n *Node, parents []*Node) error {
+		for _, field := range fields {
+			value, ok := n.takeVolatile(field)
+			if !ok {
+				continue
+			}
+			key := nodeKey(n)
+			if volatile.Nodes[key] == nil {
+				volatile.Nodes[key] = make(map[string]json.RawMessage)
+			}
+			volatile.Nodes[key][field] = value
+		}
+		return nil
+	})
+
+	return canonical, volatile, nil
+}
+
+// WriteVolatile writes the separated volatile values next to Bookmarks.json
+func (rw *RepoWriter) WriteVolatile(v *Volatile, repoPath string) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode volatile fields: %w", err)
+	}
+	if err := rw.write(filepath.Join(repoPath, VolatileFileName), data); err != nil {
+		return fmt.Errorf("failed to write volatile fields: %w", err)
+	}
+	return nil
+}
+
+// takeVolatile removes a top level field and returns its value
+func (f *File) takeVolatile(field string) (json.RawMessage, bool) {
+	switch field {
+	case "checksum":
+		return takeString(&f.Checksum)
+	case "sync_metadata":
+		return takeString(&f.SyncMetadata)
+	}
+	return takeExtra(f.Extra, field)
+}
+
+// takeVolatile removes a node field and returns its value
+func (n *Node) takeVolatile(field string) (json.RawMessage, bool) {
+	value, ok := n.removeField(field)
+	if ok {
+		n.unsetField(field)
+	}
+	return value, ok
+}
+
+func (n *Node) removeField(field string) (json.RawMessage, bool) {
+	switch field {
+	case "date_added":
+		return takeString(&n.DateAdded)
+	case "date_modified":
+		return takeString(&n.DateModified)
+	case "date_last_used":
+		return takeString(&n.DateLastUsed)
+	case "meta_info":
+		if n.MetaInfo == nil {
+			return nil, false
+		}
+		value, err := json.Marshal(n.MetaInfo)
+		n.MetaInfo = nil
+		return value, err == nil
+	}
+
+	if key, ok := strings.CutPrefix(field, "meta_info."); ok {
+		s, found := n.MetaInfo[key]
+		if !found {
+			return nil, false
+		}
+		delete(n.MetaInfo, key)
+		if len(n.MetaInfo) == 0 {
+			n.MetaInfo = nil
+			n.unsetField("meta_info")
+		}
+		// Unlike the date fields, an empty entry is still an entry
+		value, err := json.Marshal(s)
+		return value, err == nil
+	}
+
+	return takeExtra(n.Extra, field)
+}
+
+func takeString(s *string) (json.RawMessage, bool) {
+	if *s == "" {
+		return nil, false
+	}
+	value, err := json.Marshal(*s)
+	*s = ""
+	return value, err == nil
+}
+
+func takeExtra(extra map[string]json.RawMessage, field string) (json.RawMessage, bool) {
+	value, ok := extra[field]
+	if ok {
+		delete(extra, field)
+	}
+	return value, ok
+}
@@ -0,0 +1,49 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCanonicalizeRecordsEmptyMetaInfo(t *testing.T) {
+	f := testFile(testURL("10", "A"))
+	f.Roots.BookmarkBar.Children[0].MetaInfo = map[string]string{"last_visited_desktop": "", "note": "keep"}
+
+	canonical, volatile, err := f.Canonicalize([]string{"meta_info.last_visited_desktop"})
+	if err != nil {
+		t.Fatalf("Canonicalize: %v", err)
+	}
+
+	n := canonical.Roots.BookmarkBar.Children[0]
+	if _, ok := n.MetaInfo["last_visited_desktop"]; ok {
+		t.Error("the volatile meta_info entry was kept")
+	}
+	if n.MetaInfo["note"] != "keep" {
+		t.Errorf("meta_info = %v, want the other entries kept", n.MetaInfo)
+	}
+	got, ok := volatile.Nodes[nodeKey(n)]["meta_info.last_visited_desktop"]
+	if !ok || string(got) != `""` {
+		t.Errorf("volatile value = %s, %v, want the empty value recorded", got, ok)
+	}
+}
+
+func TestCanonicalizeRemovesReadFields(t *testing.T) {
+	f, err := Parse([]byte(`{"roots": {"bookmark_bar": {"id": "1", "name": "Bookmarks bar", "type": "folder", "children": [
+		{"id": "5", "name": "A", "type": "url", "url": "https://a.example/", "date_last_used": "13345678991234999", "meta_info": {"last_visited_desktop": "1"}}
+	]}}, "version": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, _, err := f.Canonicalize([]string{"date_last_used", "meta_info.last_visited_desktop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(canonical.Roots.BookmarkBar.Children[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"id":"5","name":"A","type":"url","url":"https://a.example/"}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,199 @@
+package bookmarks
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf16"
+)
+
+// ComputeChecksum returns the checksum Chrome expects in a Bookmarks file:
+// an MD5 over the id, UTF-16 title and type (and URL) of every node under
+// the roots, in tree order
+func (f *File) ComputeChecksum() string {
+	h := md5.New()
+	for _, root := range f.Roots.Nodes() {
+		root.Walk(func(n *Node, parents []*Node) error {
+			h.Write([]byte(n.ID))
+			writeUTF16(h, n.Name)
+			if n.IsURL() {
+				h.Write([]byte(TypeURL))
+				h.Write([]byte(n.URL))
+			} else {
+				h.Write([]byte(TypeFolder))
+			}
+			return nil
+		})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeUTF16 hashes s as little-endian UTF-16, the way Chrome hashes titles
+func writeUTF16(h hash.Hash, s string) {
+	units := utf16.Encode([]rune(s))
+	buf := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(buf[2*i:], u)
+	}
+	h.Write(buf)
+}
+
+// keepBackups is the number of backups WriteChromeFile keeps next to a
+// Bookmarks file, older ones are removed
+const keepBackups = 5
+
+// WriteChromeFile writes bookmarks to a Chrome Bookmarks file with a freshly
+// computed checksum. The existing file is backed up next to it first; the
+// backup path is returned.
+func WriteChromeFile(f *File, bookmarkPath string) (string, error) {
+	f.Checksum = f.ComputeChecksum()
+
+	// Chrome writes its file with three space indentation
+	data, err := json.MarshalIndent(f, "", "   ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode bookmarks: %w", err)
+	}
+
+	var backupPath string
+	if existing, err := os.ReadFile(bookmarkPath); err == nil {
+		if backupPath, err = writeBackup(bookmarkPath, existing); err != nil {
+			return "", fmt.Errorf("failed to back up bookmarks file: %w", err)
+		}
+		if err := pruneBackups(bookmarkPath); err != nil {
+			return "", err
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read bookmarks file: %w", err)
+	}
+
+	// Write to a temporary file and rename so Chrome never sees a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(bookmarkPath), "Bookmarks.bookmarked-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write bookmarks: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write bookmarks: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), bookmarkPath); err != nil {
+		return "", fmt.Errorf("failed to replace bookmarks file: %w", err)
+	}
+
+	return backupPath, nil
+}
+
+// writeBackup writes a new backup of a Bookmarks file and returns its path.
+// Names end in the time with nanoseconds, and an existing backup is never
+// replaced, so that writes in quick succession each keep their backup.
+func writeBackup(bookmarkPath string, data []byte) (string, error) {
+	for {
+		path := fmt.Sprintf("%s.bookmarked-%s.bak", bookmarkPath, time.Now().Format("20060102-150405.000000000"))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue // the clock hasn't moved on, e.g. on Windows
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return path, err
+	}
+}
+
+// pruneBackups removes all but the newest keepBackups backups of a
+// Bookmarks file
+func pruneBackups(bookmarkPath string) error {
+	entries, err := os.ReadDir(filepath.Dir(bookmarkPath))
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	// Backup names end in their time, so they sort from oldest to newest
+	prefix := filepath.Base(bookmarkPath) + ".bookmarked-"
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".bak") {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+
+	for len(backups) > keepBackups {
+		if err := os.Remove(filepath.Join(filepath.Dir(bookmarkPath), backups[0])); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// IsRunning reports whether the browser is running. Chromium-based browsers
+// keep their bookmarks in memory and overwrite the file on exit, so the file
+// must only be modified while the browser is closed.
+func (b *Browser) IsRunning() (bool, error) {
+	userDataDir, err := b.UserDataDir()
+	if err != nil {
+		return false, err
+	}
+
+	if runtime.GOOS == "windows" {
+		// Chrome holds "lockfile" open exclusively while running
+		lockPath := filepath.Join(userDataDir, "lockfile")
+		file, err := os.OpenFile(lockPath, os.O_RDWR, 0)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return true, nil
+		}
+		file.Close()
+		return false, nil
+	}
+
+	// On Linux and macOS "SingletonLock" is a symlink to "<hostname>-<pid>"
+	target, err := os.Readlink(filepath.Join(userDataDir, "SingletonLock"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s lock: %w", b.Name, err)
+	}
+
+	i := strings.LastIndex(target, "-")
+	if i < 0 {
+		return true, nil
+	}
+	hostname, _ := os.Hostname()
+	pid, err := strconv.Atoi(target[i+1:])
+	if err != nil || target[:i] != hostname {
+		// Can't check a process on another host, assume it's alive
+		return true, nil
+	}
+
+	// A stale lock is left behind if Chrome crashed
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false, nil
+	}
+	return process.Signal(syscall.Signal(0)) == nil, nil
+}
@@ -0,0 +1,85 @@
+package bookmarks
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWriteChromeFilePrunesBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Bookmarks")
+	if err := os.WriteFile(path, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := []string{
+		"Bookmarks.bookmarked-20240101-000000.bak",
+		"Bookmarks.bookmarked-20240102-000000.bak",
+		"Bookmarks.bookmarked-20240103-000000.bak",
+		"Bookmarks.bookmarked-20240104-000000.bak",
+		"Bookmarks.bookmarked-20240105-000000.bak",
+		"Bookmarks.bookmarked-20240106-000000.bak",
+	}
+	for _, name := range append(old, "Other.bookmarked-20240101-000000.bak") {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backupPath, err := WriteChromeFile(testFile(testURL("10", "A")), path)
+	if err != nil {
+		t.Fatalf("WriteChromeFile: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	want := append([]string{"Bookmarks"}, old[2:]...)
+	want = append(want, filepath.Base(backupPath), "Other.bookmarked-20240101-000000.bak")
+	sort.Strings(want)
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("files after writing:\n  got  %q\n  want %q", names, want)
+	}
+}
+
+func TestWriteChromeFileKeepsEachBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Bookmarks")
+	if err := os.WriteFile(path, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes in the same second each get their own backup
+	var backups []string
+	for i := 0; i < 3; i++ {
+		backupPath, err := WriteChromeFile(testFile(testURL("10", "A")), path)
+		if err != nil {
+			t.Fatalf("WriteChromeFile: %v", err)
+		}
+		backups = append(backups, backupPath)
+	}
+
+	if !sort.StringsAreSorted(backups) {
+		t.Errorf("backups %q don't sort from oldest to newest", backups)
+	}
+	for i, backupPath := range backups {
+		data, err := os.ReadFile(backupPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && string(data) != "{}" {
+			t.Errorf("first backup = %q, want the original file", data)
+		}
+		if i > 0 && backupPath == backups[i-1] {
+			t.Errorf("backup %s written twice", backupPath)
+		}
+	}
+}
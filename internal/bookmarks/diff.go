@@ -0,0 +1,179 @@
+package bookmarks
+
+import (
+	"fmt"
+	"io"
+)
+
+// ChangeKind describes how a node changed between two versions
+type ChangeKind string
+
+const (
+	Added      ChangeKind = "added"
+	Removed    ChangeKind = "removed"
+	Renamed    ChangeKind = "renamed"
+	Moved      ChangeKind = "moved"
+	URLChanged ChangeKind = "url_changed"
+)
+
+// Change is a single semantic difference between two bookmark files. A node
+// that was both renamed and moved produces two changes.
+type Change struct {
+	Kind      ChangeKind `json:"kind"`
+	Type      string     `json:"type"`
+	GUID      string     `json:"guid,omitempty"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	URL       string     `json:"url,omitempty"`
+	Folder    string     `json:"folder"`
+	OldName   string     `json:"old_name,omitempty"`
+	OldURL    string     `json:"old_url,omitempty"`
+	OldFolder string     `json:"old_folder,omitempty"`
+}
+
+// ChangeSet is the list of changes between two bookmark files
+type ChangeSet struct {
+	Changes []Change `json:"changes"`
+}
+
+// indexedNode is a node together with its location in the tree
+type indexedNode struct {
+	node    *Node
+	parents []*Node
+}
+
+// Diff compares two bookmark files, matching nodes by GUID (or by ID for
+// nodes without one). Either file may be nil to represent an empty tree.
+func Diff(old, new *File) *ChangeSet {
+	oldIndex, oldOrder := indexNodes(old)
+	newIndex, newOrder := indexNodes(new)
+
+	cs := &ChangeSet{Changes: []Change{}}
+
+	for _, key := range newOrder {
+		n := newIndex[key]
+		o, existed := oldIndex[key]
+		if !existed {
+			cs.Changes = append(cs.Changes, newChange(Added, n))
+			continue
+		}
+
+		if o.node.Name != n.node.Name {
+			c := newChange(Renamed, n)
+			c.OldName = o.node.Name
+			cs.Changes = append(cs.Changes, c)
+		}
+		if parentKey(o.parents) != parentKey(n.parents) {
+			c := newChange(Moved, n)
+			c.OldFolder = FolderPath(o.parents)
+			cs.Changes = append(cs.Changes, c)
+		}
+		if o.node.URL != n.node.URL {
+			c := newChange(URLChanged, n)
+			c.OldURL = o.node.URL
+			cs.Changes = append(cs.Changes, c)
+		}
+	}
+
+	for _, key := range oldOrder {
+		if _, ok := newIndex[key]; !ok {
+			cs.Changes = append(cs.Changes, newChange(Removed, oldIndex[key]))
+		}
+	}
+
+	return cs
+}
+
+// IsEmpty reports whether there are no changes
+func (cs *ChangeSet) IsEmpty() bool {
+	return len(cs.Changes) == 0
+}
+
+// WriteText writes a human-readable description of the changes
+func (cs *ChangeSet) WriteText(w io.Writer) error {
+	if cs.IsEmpty() {
+		_, err := fmt.Fprintln(w, "No bookmark changes")
+		return err
+	}
+
+	for _, c := range cs.Changes {
+		if _, err := fmt.Fprintln(w, c.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns a one-line description of the change
+func (c Change) String() string {
+	label := c.Name
+	if c.URL != "" {
+		label = fmt.Sprintf("%s <%s>", c.Name, c.URL)
+	}
+
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ added    %s: %s", c.Folder, label)
+	case Removed:
+		return fmt.Sprintf("- removed  %s: %s", c.Folder, label)
+	case Renamed:
+		return fmt.Sprintf("~ renamed  %s: %q -> %q", c.Folder, c.OldName, c.Name)
+	case Moved:
+		return fmt.Sprintf("> moved    %q: %s -> %s", c.Name, c.OldFolder, c.Folder)
+	case URLChanged:
+		return fmt.Sprintf("* url      %s: %q %s -> %s", c.Folder, c.Name, c.OldURL, c.URL)
+	default:
+		return fmt.Sprintf("? %s %s: %s", c.Kind, c.Folder, label)
+	}
+}
+
+func newChange(kind ChangeKind, in indexedNode) Change {
+	return Change{
+		Kind:   kind,
+		Type:   in.node.Type,
+		GUID:   in.node.GUID,
+		ID:     in.node.ID,
+		Name:   in.node.Name,
+		URL:    in.node.URL,
+		Folder: FolderPath(in.parents),
+	}
+}
+
+// nodeKey identifies a node across versions of a file
+func nodeKey(n *Node) string {
+	if n.GUID != "" {
+		return "guid:" + n.GUID
+	}
+	return "id:" + n.ID
+}
+
+// parentKey identifies the folder containing a node
+func parentKey(parents []*Node) string {
+	if len(parents) == 0 {
+		return ""
+	}
+	return nodeKey(parents[len(parents)-1])
+}
+
+// indexNodes maps every non-root node by key and returns the keys in tree order
+func indexNodes(f *File) (map[string]indexedNode, []string) {
+	index := make(map[string]indexedNode)
+	var order []string
+	if f == nil {
+		return index, order
+	}
+
+	f.Walk(func(n *Node, parents []*Node) error {
+		if len(parents) == 0 {
+			return nil // the permanent root folders can't change
+		}
+		key := nodeKey(n)
+		if _, dup := index[key]; !dup {
+			order = append(order, key)
+		}
+		index[key] = indexedNode{node: n, parents: parents}
+		return nil
+	})
+
+	return index, order
+}
@@ -0,0 +1,93 @@
+package bookmarks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output formats bookmarks can be written in
+const (
+	OutputJSON         = "json"
+	OutputHTML         = "html"
+	OutputMarkdown     = "markdown"
+	OutputMarkdownTree = "markdown-tree"
+	OutputCSV          = "csv"
+	OutputJSONL        = "jsonl"
+)
+
+// Exporter writes bookmarks in one output format. Single file formats set
+// Write, formats producing a directory tree set WriteDir.
+type Exporter struct {
+	Format   string // Name used in the outputs setting and --format
+	FileName string // File or directory written to the repository
+	Write    func(f *File, w io.Writer) error
+	WriteDir func(f *File, dir string, write WriteFileFunc) error
+}
+
+// Exporters lists the supported output formats
+var Exporters = []*Exporter{
+	{Format: OutputJSON, FileName: RepoFileName, Write: writeJSON},
+	{Format: OutputHTML, FileName: "bookmarks.html", Write: WriteHTML},
+	{Format: OutputMarkdown, FileName: "BOOKMARKS.md", Write: WriteMarkdown},
+	{Format: OutputMarkdownTree, FileName: "bookmarks", WriteDir: WriteMarkdownTree},
+	{Format: OutputCSV, FileName: "bookmarks.csv", Write: WriteCSV},
+	{Format: OutputJSONL, FileName: "bookmarks.jsonl", Write: WriteJSONL},
+}
+
+// FindExporter returns the exporter for a format
+func FindExporter(format string) (*Exporter, error) {
+	for _, e := range Exporters {
+		if strings.EqualFold(e.Format, format) {
+			return e, nil
+		}
+	}
+
+	formats := make([]string, len(Exporters))
+	for i, e := range Exporters {
+		formats[i] = e.Format
+	}
+	return nil, fmt.Errorf("unknown output format %q, expected one of: %s", format, strings.Join(formats, ", "))
+}
+
+// IsDir reports whether the format produces a directory rather than a file
+func (e *Exporter) IsDir() bool {
+	return e.WriteDir != nil
+}
+
+// writeToRepo writes bookmarks in the exporter's format to dir. A directory
+// tree is replaced entirely so files of removed folders don't linger.
+func (e *Exporter) writeToRepo(f *File, dir string, write WriteFileFunc) error {
+	if e.IsDir() {
+		target := filepath.Join(dir, e.FileName)
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("failed to remove old %s: %w", e.FileName, err)
+		}
+		if err := e.WriteDir(f, target, write); err != nil {
+			return fmt.Errorf("failed to export %s: %w", e.Format, err)
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := e.Write(f, &buf); err != nil {
+		return fmt.Errorf("failed to export %s: %w", e.Format, err)
+	}
+
+	if err := write(filepath.Join(dir, e.FileName), buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", e.FileName, err)
+	}
+	return nil
+}
+
+func writeJSON(f *File, w io.Writer) error {
+	formatted, err := f.Format()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
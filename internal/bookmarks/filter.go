@@ -0,0 +1,256 @@
+package bookmarks
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Rule matches bookmarks by folder, URL, domain and title. All conditions
+// that are set must match.
+type Rule struct {
+	Folder string // Folder path glob, e.g. "Bookmarks bar/Private/**"
+	URL    string // Regular expression matched against the URL
+	Domain string // Host wildcard, e.g. "*.internal.corp"
+	Title  string // Title wildcard, e.g. "*salary*"
+}
+
+// String describes the rule's conditions
+func (r Rule) String() string {
+	var parts []string
+	for _, c := range []struct{ name, value string }{
+		{"folder", r.Folder}, {"url", r.URL}, {"domain", r.Domain}, {"title", r.Title},
+	} {
+		if c.value != "" {
+			parts = append(parts, fmt.Sprintf("%s %q", c.name, c.value))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Filter decides which bookmarks are synced. Bookmarks matching an exclude
+// rule are dropped; if there are include rules, only bookmarks matching one
+// of them are kept.
+type Filter struct {
+	include []*compiledRule
+	exclude []*compiledRule
+}
+
+type compiledRule struct {
+	rule   Rule
+	folder []string
+	url    *regexp.Regexp
+	domain *regexp.Regexp
+	title  *regexp.Regexp
+}
+
+// Dropped is a bookmark or folder left out by a filter
+type Dropped struct {
+	Node   *Node
+	Folder string // Path of the folder containing the node
+	Reason string // e.g. `exclude folder "Bookmarks bar/Private/**"`
+}
+
+// String describes the dropped node and the reason
+func (d Dropped) String() string {
+	what := fmt.Sprintf("%s: %s <%s>", d.Folder, d.Node.Name, d.Node.URL)
+	if d.Node.IsFolder() {
+		what = fmt.Sprintf("%s/%s/ (folder, %d bookmarks)", d.Folder, d.Node.Name, countBookmarks(d.Node))
+	}
+	return fmt.Sprintf("%s  (%s)", what, d.Reason)
+}
+
+// NewFilter compiles include and exclude rules
+func NewFilter(include, exclude []Rule) (*Filter, error) {
+	f := &Filter{}
+	for _, r := range include {
+		c, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include rule: %w", err)
+		}
+		f.include = append(f.include, c)
+	}
+	for _, r := range exclude {
+		c, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude rule: %w", err)
+		}
+		f.exclude = append(f.exclude, c)
+	}
+	return f, nil
+}
+
+func compileRule(r Rule) (*compiledRule, error) {
+	if r.Folder == "" && r.URL == "" && r.Domain == "" && r.Title == "" {
+		return nil, fmt.Errorf("a rule needs at least one of folder, url, domain or title")
+	}
+
+	c := &compiledRule{rule: r}
+	if r.Folder != "" {
+		c.folder = strings.Split(strings.ToLower(strings.Trim(r.Folder, "/")), "/")
+		for _, segment := range c.folder {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("bad folder pattern %q: %w", r.Folder, err)
+			}
+		}
+	}
+	if r.URL != "" {
+		var err error
+		if c.url, err = regexp.Compile(r.URL); err != nil {
+			return nil, fmt.Errorf("bad url pattern: %w", err)
+		}
+	}
+	if r.Domain != "" {
+		c.domain = wildcardRegexp(r.Domain)
+	}
+	if r.Title != "" {
+		c.title = wildcardRegexp(r.Title)
+	}
+	return c, nil
+}
+
+// wildcardRegexp turns a case-insensitive pattern with * and ? into a
+// regular expression matching the whole string
+func wildcardRegexp(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("(?i)^" + quoted + "$")
+}
+
+// matchFolder reports whether a folder path matches the rule's glob, where
+// "**" matches any number of folders, including none
+func (c *compiledRule) matchFolder(folder []string) bool {
+	return matchSegments(c.folder, folder)
+}
+
+func matchSegments(pattern, names []string) bool {
+	if len(pattern) == 0 {
+		return len(names) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(names); i++ {
+			if matchSegments(pattern[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(names) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], names[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], names[1:])
+}
+
+// matchBookmark reports whether a bookmark in the given folder matches
+func (c *compiledRule) matchBookmark(n *Node, folder []string) bool {
+	if c.folder != nil && !c.matchFolder(folder) {
+		return false
+	}
+	if c.url != nil && !c.url.MatchString(n.URL) {
+		return false
+	}
+	if c.domain != nil {
+		u, err := url.Parse(n.URL)
+		if err != nil || !c.domain.MatchString(u.Hostname()) {
+			return false
+		}
+	}
+	if c.title != nil && !c.title.MatchString(n.Name) {
+		return false
+	}
+	return true
+}
+
+// matchesFolder reports whether a folder-only rule covers a whole folder
+func (c *compiledRule) matchesFolder(folder []string) bool {
+	return c.folder != nil && c.url == nil && c.domain == nil && c.title == nil && c.matchFolder(folder)
+}
+
+// Apply returns a copy of the bookmarks without the filtered ones, and what
+// was dropped. Folders matched by a folder-only exclude rule are dropped
+// whole; folders emptied by include rules are dropped too.
+func (flt *Filter) Apply(f *File) (*File, []Dropped, error) {
+	filtered, err := f.Clone()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var dropped []Dropped
+	for _, root := range filtered.Roots.Nodes() {
+		flt.filterFolder(root, []string{strings.ToLower(root.Name)}, root.Name, &dropped)
+	}
+	return filtered, dropped, nil
+}
+
+func (flt *Filter) filterFolder(folder *Node, names []string, folderPath string, dropped *[]Dropped) {
+	kept := folder.Children[:0]
+	for _, child := range folder.Children {
+		if child.IsFolder() {
+			childNames := append(names[:len(names):len(names)], strings.ToLower(child.Name))
+			if rule := flt.excludedFolder(childNames); rule != nil {
+				*dropped = append(*dropped, Dropped{Node: child, Folder: folderPath, Reason: "exclude " + rule.rule.String()})
+				continue
+			}
+
+			hadBookmarks := countBookmarks(child) > 0
+			flt.filterFolder(child, childNames, folderPath+"/"+child.Name, dropped)
+			if len(flt.include) > 0 && hadBookmarks && countBookmarks(child) == 0 {
+				continue
+			}
+			kept = append(kept, child)
+			continue
+		}
+
+		if reason := flt.dropReason(child, names); reason != "" {
+			*dropped = append(*dropped, Dropped{Node: child, Folder: folderPath, Reason: reason})
+			continue
+		}
+		kept = append(kept, child)
+	}
+	folder.Children = kept
+}
+
+// excludedFolder returns the folder-only exclude rule covering a folder
+func (flt *Filter) excludedFolder(names []string) *compiledRule {
+	for _, c := range flt.exclude {
+		if c.matchesFolder(names) {
+			return c
+		}
+	}
+	return nil
+}
+
+// dropReason explains why a bookmark is filtered, or returns ""
+func (flt *Filter) dropReason(n *Node, folder []string) string {
+	for _, c := range flt.exclude {
+		if c.matchBookmark(n, folder) {
+			return "exclude " + c.rule.String()
+		}
+	}
+	if len(flt.include) == 0 {
+		return ""
+	}
+	for _, c := range flt.include {
+		if c.matchBookmark(n, folder) {
+			return ""
+		}
+	}
+	return "no include rule matches"
+}
+
+func countBookmarks(n *Node) int {
+	count := 0
+	n.Walk(func(child *Node, parents []*Node) error {
+		if child.IsURL() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
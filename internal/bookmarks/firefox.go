@@ -0,0 +1,372 @@
+package bookmarks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mozLz4Magic starts every Firefox .jsonlz4 file
+const mozLz4Magic = "mozLz40\x00"
+
+// Firefox bookmark type codes
+const (
+	firefoxTypeURL       = 1
+	firefoxTypeFolder    = 2
+	firefoxTypeSeparator = 3
+)
+
+// Firefox root folder names as found in the "root" field of backups
+const (
+	firefoxRootMenu    = "bookmarksMenuFolder"
+	firefoxRootToolbar = "toolbarFolder"
+	firefoxRootUnfiled = "unfiledBookmarksFolder"
+	firefoxRootMobile  = "mobileFolder"
+)
+
+// firefoxNode is a node of a Firefox bookmarks backup
+type firefoxNode struct {
+	GUID         string         `json:"guid"`
+	Title        string         `json:"title"`
+	ID           int64          `json:"id"`
+	TypeCode     int            `json:"typeCode"`
+	URI          string         `json:"uri"`
+	DateAdded    int64          `json:"dateAdded"`    // Microseconds since the Unix epoch
+	LastModified int64          `json:"lastModified"` // Microseconds since the Unix epoch
+	Root         string         `json:"root"`
+	Children     []*firefoxNode `json:"children"`
+}
+
+// firefoxBackupDir returns the directory Firefox writes bookmark backups to
+func firefoxBackupDir(profileDir string) string {
+	return filepath.Join(profileDir, "bookmarkbackups")
+}
+
+// LoadFirefoxBackups reads the newest bookmark backup (.jsonlz4 or .json) in a
+// Firefox bookmarkbackups directory
+func LoadFirefoxBackups(backupDir string) (*File, error) {
+	latest, _, err := latestFirefoxBackup(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	return LoadFirefoxBackup(latest)
+}
+
+// FirefoxBackupTime returns when the newest bookmark backup in a Firefox
+// bookmarkbackups directory was written. Firefox writes one about once a
+// day, so changes made since then aren't in it yet.
+func FirefoxBackupTime(backupDir string) (time.Time, error) {
+	_, modTime, err := latestFirefoxBackup(backupDir)
+	return modTime, err
+}
+
+// LoadFirefoxBackup reads a Firefox bookmarks backup file and converts it to
+// the Chrome bookmark model
+func LoadFirefoxBackup(backupPath string) (*File, error) {
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Firefox bookmarks backup: %w", err)
+	}
+
+	if bytes.HasPrefix(data, []byte(mozLz4Magic)) {
+		if data, err = decodeMozLz4(data); err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", backupPath, err)
+		}
+	}
+
+	var root firefoxNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse Firefox bookmarks backup: %w", err)
+	}
+
+	return convertFirefox(&root)
+}
+
+// latestFirefoxBackup returns the most recently written backup in backupDir
+// and its modification time
+func latestFirefoxBackup(backupDir string) (string, time.Time, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read Firefox bookmark backups: %w", err)
+	}
+
+	var latest string
+	var latestMod time.Time
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "bookmarks-") ||
+			!(strings.HasSuffix(name, ".jsonlz4") || strings.HasSuffix(name, ".json")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if mod := info.ModTime(); latest == "" || mod.After(latestMod) {
+			latest, latestMod = filepath.Join(backupDir, name), mod
+		}
+	}
+
+	if latest == "" {
+		return "", time.Time{}, fmt.Errorf("no Firefox bookmark backups found in: %s", backupDir)
+	}
+	return latest, latestMod, nil
+}
+
+// convertFirefox maps a Firefox backup onto Chrome's roots: the toolbar
+// becomes the bookmarks bar, unfiled bookmarks go to other bookmarks with
+// the bookmarks menu as a folder inside it, and mobile bookmarks are synced
+func convertFirefox(root *firefoxNode) (*File, error) {
+	roots := make(map[string]*firefoxNode)
+	for _, child := range root.Children {
+		roots[child.Root] = child
+	}
+
+	toolbar, unfiled := roots[firefoxRootToolbar], roots[firefoxRootUnfiled]
+	if toolbar == nil || unfiled == nil {
+		return nil, fmt.Errorf("Firefox bookmarks backup is missing its root folders")
+	}
+
+	f := &File{Version: 1}
+	f.Roots.BookmarkBar = convertFirefoxNode(toolbar)
+	f.Roots.BookmarkBar.Name = "Bookmarks bar"
+
+	f.Roots.Other = convertFirefoxNode(unfiled)
+	f.Roots.Other.Name = "Other bookmarks"
+	if menu := roots[firefoxRootMenu]; menu != nil {
+		menuNode := convertFirefoxNode(menu)
+		menuNode.Name = "Bookmarks Menu"
+		f.Roots.Other.Children = append([]*Node{menuNode}, f.Roots.Other.Children...)
+	}
+
+	if mobile := roots[firefoxRootMobile]; mobile != nil {
+		f.Roots.Synced = convertFirefoxNode(mobile)
+	} else {
+		f.Roots.Synced = &Node{ID: strconv.FormatInt(root.ID, 10), Type: TypeFolder}
+	}
+	f.Roots.Synced.Name = "Mobile bookmarks"
+
+	return f, nil
+}
+
+func convertFirefoxNode(fn *firefoxNode) *Node {
+	n := &Node{
+		ID:        strconv.FormatInt(fn.ID, 10),
+		GUID:      fn.GUID,
+		Name:      fn.Title,
+		DateAdded: firefoxTime(fn.DateAdded),
+	}
+
+	if fn.TypeCode == firefoxTypeURL {
+		n.Type = TypeURL
+		n.URL = fn.URI
+		return n
+	}
+
+	n.Type = TypeFolder
+	n.DateModified = firefoxTime(fn.LastModified)
+	n.Children = []*Node{}
+	for _, child := range fn.Children {
+		if child.TypeCode == firefoxTypeSeparator {
+			continue
+		}
+		n.Children = append(n.Children, convertFirefoxNode(child))
+	}
+	return n
+}
+
+// firefoxTime converts microseconds since the Unix epoch to a Chrome timestamp
+func firefoxTime(us int64) string {
+	if us == 0 {
+		return "0"
+	}
+	return strconv.FormatInt(us+webkitEpochOffset, 10)
+}
+
+// decodeMozLz4 decompresses Mozilla's LZ4 container: a magic string, the
+// decompressed size as a little-endian uint32, then a single LZ4 block
+func decodeMozLz4(data []byte) ([]byte, error) {
+	header := len(mozLz4Magic) + 4
+	if len(data) < header {
+		return nil, errors.New("truncated mozlz4 header")
+	}
+	size := binary.LittleEndian.Uint32(data[len(mozLz4Magic):header])
+	return decodeLZ4Block(data[header:], int(size))
+}
+
+// decodeLZ4Block decompresses a raw LZ4 block of known decompressed size
+func decodeLZ4Block(src []byte, size int) ([]byte, error) {
+	errCorrupt := errors.New("corrupt LZ4 block")
+	dst := make([]byte, 0, size)
+
+	// readLength extends a 4-bit length with following bytes while they are 255
+	readLength := func(i, length int) (int, int, error) {
+		if length != 15 {
+			return i, length, nil
+		}
+		for {
+			if i >= len(src) {
+				return 0, 0, errCorrupt
+			}
+			b := src[i]
+			i++
+			length += int(b)
+			if b != 255 {
+				return i, length, nil
+			}
+		}
+	}
+
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		var literals int
+		var err error
+		if i, literals, err = readLength(i, int(token>>4)); err != nil {
+			return nil, err
+		}
+		if i+literals > len(src) {
+			return nil, errCorrupt
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+
+		// The last sequence only contains literals
+		if i == len(src) {
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, errCorrupt
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, errCorrupt
+		}
+
+		var match int
+		if i, match, err = readLength(i, int(token&15)); err != nil {
+			return nil, err
+		}
+		match += 4
+
+		// Copy byte by byte since the match may overlap the output
+		start := len(dst) - offset
+		for k := 0; k < match; k++ {
+			dst = append(dst, dst[start+k])
+		}
+	}
+
+	if len(dst) != size {
+		return nil, fmt.Errorf("LZ4 block decompressed to %d bytes, expected %d", len(dst), size)
+	}
+	return dst, nil
+}
+
+// firefoxProfiles lists the profiles in a Firefox profiles.ini
+func firefoxProfiles(b *Browser, dataDir string) ([]Profile, error) {
+	sections, err := readINI(filepath.Join(dataDir, "profiles.ini"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles.ini: %w", err)
+	}
+
+	var profiles []Profile
+	for _, section := range sections {
+		if !strings.HasPrefix(section.name, "Profile") || section.values["Path"] == "" {
+			continue
+		}
+
+		path := filepath.FromSlash(section.values["Path"])
+		profileDir := path
+		if section.values["IsRelative"] == "1" {
+			profileDir = filepath.Join(dataDir, path)
+		}
+
+		backupDir := firefoxBackupDir(profileDir)
+		_, _, latestErr := latestFirefoxBackup(backupDir)
+
+		name := section.values["Name"]
+		if name == "" {
+			name = filepath.Base(path)
+		}
+		profiles = append(profiles, Profile{
+			Browser:      b,
+			Dir:          section.values["Path"],
+			Name:         name,
+			BookmarkPath: backupDir,
+			HasBookmarks: latestErr == nil,
+		})
+	}
+
+	return profiles, nil
+}
+
+// firefoxDefaultProfile returns the path (as written in profiles.ini) of the
+// profile Firefox starts by default
+func firefoxDefaultProfile(dataDir string) (string, error) {
+	sections, err := readINI(filepath.Join(dataDir, "profiles.ini"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read profiles.ini: %w", err)
+	}
+
+	// Since Firefox 67 each installation has its own default profile
+	for _, section := range sections {
+		if strings.HasPrefix(section.name, "Install") && section.values["Default"] != "" {
+			return section.values["Default"], nil
+		}
+	}
+	for _, section := range sections {
+		if strings.HasPrefix(section.name, "Profile") && section.values["Default"] == "1" {
+			return section.values["Path"], nil
+		}
+	}
+	return "", fmt.Errorf("no default Firefox profile in profiles.ini")
+}
+
+// iniSection is a section of an INI file
+type iniSection struct {
+	name   string
+	values map[string]string
+}
+
+// readINI parses the simple INI format of Firefox's profiles.ini
+func readINI(path string) ([]iniSection, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sections []iniSection
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			sections = append(sections, iniSection{
+				name:   line[1 : len(line)-1],
+				values: make(map[string]string),
+			})
+		case len(sections) > 0:
+			if key, value, ok := strings.Cut(line, "="); ok {
+				sections[len(sections)-1].values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	return sections, scanner.Err()
+}
@@ -0,0 +1,38 @@
+package bookmarks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFirefoxBackupTime(t *testing.T) {
+	dir := t.TempDir()
+	newest := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	for name, mod := range map[string]time.Time{
+		"bookmarks-2024-01-01_10_abc.jsonlz4": newest.Add(-24 * time.Hour),
+		"bookmarks-2024-01-02_12_def.jsonlz4": newest,
+		"other.jsonlz4":                       time.Now(),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := FirefoxBackupTime(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(newest) {
+		t.Errorf("FirefoxBackupTime = %s, want %s", got, newest)
+	}
+
+	if _, err := FirefoxBackupTime(t.TempDir()); err == nil {
+		t.Error("FirefoxBackupTime succeeded without backups")
+	}
+}
@@ -0,0 +1,93 @@
+package bookmarks
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one bookmark of a flat export, with its folder as a path
+type Record struct {
+	Folder       string `json:"folder"`
+	Title        string `json:"title"`
+	URL          string `json:"url"`
+	Domain       string `json:"domain"`
+	DateAdded    string `json:"date_added"`     // RFC 3339, empty if unknown
+	DateLastUsed string `json:"date_last_used"` // RFC 3339, empty if never used
+	GUID         string `json:"guid"`
+	Depth        int    `json:"depth"` // Number of folders above the bookmark, including the root
+}
+
+// csvHeader names the CSV columns in the order of Record's fields
+var csvHeader = []string{"folder", "title", "url", "domain", "date_added", "date_last_used", "guid", "depth"}
+
+// Records flattens the bookmarks into one record per bookmark, in tree order
+func (f *File) Records() []Record {
+	var records []Record
+	f.Walk(func(n *Node, parents []*Node) error {
+		if !n.IsURL() {
+			return nil
+		}
+		records = append(records, Record{
+			Folder:       FolderPath(parents),
+			Title:        n.Name,
+			URL:          n.URL,
+			Domain:       Domain(n.URL),
+			DateAdded:    formatRFC3339(n.Added()),
+			DateLastUsed: formatRFC3339(n.LastUsed()),
+			GUID:         n.GUID,
+			Depth:        len(parents),
+		})
+		return nil
+	})
+	return records
+}
+
+// Domain returns the host of a URL without a leading "www.", or "" if the
+// URL has no host
+func Domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// WriteCSV writes one row per bookmark with a header row
+func WriteCSV(f *File, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range f.Records() {
+		row := []string{r.Folder, r.Title, r.URL, r.Domain, r.DateAdded, r.DateLastUsed, r.GUID, strconv.Itoa(r.Depth)}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONL writes one JSON object per bookmark and line
+func WriteJSONL(f *File, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, r := range f.Records() {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatRFC3339(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,80 @@
+package bookmarks
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// htmlHeader starts a Netscape bookmark file, the format every browser and
+// bookmark manager can import
+const htmlHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+`
+
+// WriteHTML writes bookmarks as a Netscape bookmark file. Like Chrome's own
+// export, the bookmarks bar is marked as the toolbar folder and other
+// bookmarks are listed at the top level.
+func WriteHTML(f *File, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(htmlHeader)
+	bw.WriteString("<DL><p>\n")
+
+	if bar := f.Roots.BookmarkBar; bar != nil {
+		writeHTMLFolder(bw, bar, 1, true)
+	}
+	if other := f.Roots.Other; other != nil {
+		for _, child := range other.Children {
+			writeHTMLNode(bw, child, 1)
+		}
+	}
+	if synced := f.Roots.Synced; synced != nil && len(synced.Children) > 0 {
+		writeHTMLFolder(bw, synced, 1, false)
+	}
+
+	bw.WriteString("</DL><p>\n")
+	return bw.Flush()
+}
+
+func writeHTMLNode(w *bufio.Writer, n *Node, depth int) {
+	if n.IsFolder() {
+		writeHTMLFolder(w, n, depth, false)
+		return
+	}
+
+	indent := strings.Repeat("    ", depth)
+	fmt.Fprintf(w, "%s<DT><A HREF=\"%s\"%s>%s</A>\n",
+		indent, html.EscapeString(n.URL), htmlDate("ADD_DATE", n.DateAdded), html.EscapeString(n.Name))
+}
+
+func writeHTMLFolder(w *bufio.Writer, n *Node, depth int, toolbar bool) {
+	indent := strings.Repeat("    ", depth)
+	attrs := htmlDate("ADD_DATE", n.DateAdded) + htmlDate("LAST_MODIFIED", n.DateModified)
+	if toolbar {
+		attrs += ` PERSONAL_TOOLBAR_FOLDER="true"`
+	}
+
+	fmt.Fprintf(w, "%s<DT><H3%s>%s</H3>\n", indent, attrs, html.EscapeString(n.Name))
+	fmt.Fprintf(w, "%s<DL><p>\n", indent)
+	for _, child := range n.Children {
+		writeHTMLNode(w, child, depth+1)
+	}
+	fmt.Fprintf(w, "%s</DL><p>\n", indent)
+}
+
+// htmlDate formats a Chrome timestamp as an attribute in Unix seconds, or
+// returns "" if the timestamp is unset
+func htmlDate(name, timestamp string) string {
+	t := ParseTime(timestamp)
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(" %s=\"%d\"", name, t.Unix())
+}
@@ -0,0 +1,341 @@
+package bookmarks
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// ImportResult counts what Import did
+type ImportResult struct {
+	Added   int // Bookmarks added
+	Skipped int // Bookmarks skipped because their URL already exists
+}
+
+// ReadImport parses a file to import: a Netscape bookmark HTML file, a
+// Chrome-format Bookmarks JSON file, or a CSV export. The bookmarks are
+// returned as the children of a folder node.
+func ReadImport(path string) (*Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import file: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return parseImportJSON(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return parseImportHTML(trimmed)
+	default:
+		return parseImportCSV(trimmed)
+	}
+}
+
+// parseImportJSON converts each non-empty root of a Chrome Bookmarks file to
+// a folder named after it
+func parseImportJSON(data []byte) (*Node, error) {
+	f, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := &Node{Type: TypeFolder}
+	for _, root := range f.Roots.Nodes() {
+		if len(root.Children) > 0 {
+			folder.Children = append(folder.Children, root)
+		}
+	}
+	return folder, nil
+}
+
+// parseImportHTML reads a Netscape bookmark file, where each <H3> names a
+// folder whose contents follow in a <DL> list
+func parseImportHTML(data []byte) (*Node, error) {
+	root := &Node{Type: TypeFolder}
+	stack := []*Node{root}
+	var pending *Node // Folder named by the last <H3>, opened by the next <DL>
+	var current *Node // Node whose name is being read
+
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				return root, nil
+			}
+			return nil, fmt.Errorf("failed to parse bookmarks HTML: %w", z.Err())
+
+		case html.StartTagToken:
+			tag, _ := z.TagName()
+			attrs := htmlAttrs(z)
+			parent := stack[len(stack)-1]
+
+			switch string(tag) {
+			case "h3":
+				current = &Node{
+					Type:         TypeFolder,
+					DateAdded:    htmlTime(attrs["add_date"]),
+					DateModified: htmlTime(attrs["last_modified"]),
+				}
+				parent.Children = append(parent.Children, current)
+				pending = current
+			case "a":
+				current = &Node{
+					Type:      TypeURL,
+					URL:       attrs["href"],
+					DateAdded: htmlTime(attrs["add_date"]),
+				}
+				if current.URL != "" {
+					parent.Children = append(parent.Children, current)
+				}
+			case "dl":
+				if pending != nil {
+					stack = append(stack, pending)
+					pending = nil
+				} else {
+					// A list without a heading, e.g. the top level list
+					stack = append(stack, parent)
+				}
+			}
+
+		case html.EndTagToken:
+			tag, _ := z.TagName()
+			switch string(tag) {
+			case "h3", "a":
+				if current != nil {
+					current.Name = strings.TrimSpace(current.Name)
+				}
+				current = nil
+			case "dl":
+				if len(stack) > 1 {
+					stack = stack[:len(stack)-1]
+				}
+			}
+
+		case html.TextToken:
+			if current != nil {
+				current.Name += string(z.Text())
+			}
+		}
+	}
+}
+
+// htmlAttrs returns the attributes of the current tag by lowercase name
+func htmlAttrs(z *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		key, value, more := z.TagAttr()
+		if len(key) > 0 {
+			attrs[string(key)] = string(value)
+		}
+		if !more {
+			return attrs
+		}
+	}
+}
+
+// htmlTime converts a Unix seconds attribute to a Chrome timestamp
+func htmlTime(attr string) string {
+	seconds, err := strconv.ParseInt(attr, 10, 64)
+	if err != nil || seconds == 0 {
+		return ""
+	}
+	return FormatTime(time.Unix(seconds, 0))
+}
+
+// parseImportCSV reads a CSV export, re-creating folders from the folder
+// column
+func parseImportCSV(data []byte) (*Node, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("CSV file has no url column, expected the columns of 'bookmarked export --format csv'")
+	}
+	get := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	root := &Node{Type: TypeFolder}
+	for _, row := range rows[1:] {
+		url := get(row, "url")
+		if url == "" {
+			continue
+		}
+		var added string
+		if t, err := time.Parse(time.RFC3339, get(row, "date_added")); err == nil {
+			added = FormatTime(t)
+		}
+
+		folder := root
+		if path := strings.Trim(get(row, "folder"), "/"); path != "" {
+			folder = ensureFolder(root, strings.Split(path, "/"))
+		}
+		folder.Children = append(folder.Children, &Node{
+			Type:      TypeURL,
+			Name:      get(row, "title"),
+			URL:       url,
+			DateAdded: added,
+		})
+	}
+	return root, nil
+}
+
+// ensureFolder returns the folder below parent at the path of names,
+// creating missing folders
+func ensureFolder(parent *Node, names []string) *Node {
+	for _, name := range names {
+		var next *Node
+		for _, child := range parent.Children {
+			if child.IsFolder() && child.Name == name {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			next = &Node{Type: TypeFolder, Name: name, Children: []*Node{}}
+			parent.Children = append(parent.Children, next)
+		}
+		parent = next
+	}
+	return parent
+}
+
+// Import merges the children of src into the folder at path, e.g. "Other
+// bookmarks/Imported", creating it if needed. Folders are merged with
+// existing folders of the same name, and bookmarks whose URL already exists
+// anywhere in the file are skipped. Imported nodes get new IDs and GUIDs.
+func (f *File) Import(src *Node, path string) (ImportResult, error) {
+	var result ImportResult
+
+	names := strings.Split(strings.Trim(path, "/"), "/")
+	root, _ := f.FindFolder(names[0])
+	if root == nil {
+		return result, fmt.Errorf("root folder %q not found, the path must start with e.g. \"Other bookmarks\"", names[0])
+	}
+
+	existing := make(map[string]bool)
+	f.Walk(func(n *Node, parents []*Node) error {
+		if n.IsURL() {
+			existing[n.URL] = true
+		}
+		return nil
+	})
+
+	now := FormatTime(time.Now())
+	nextID := f.MaxID() + 1
+	newNode := func(n *Node) (*Node, error) {
+		guid, err := NewGUID()
+		if err != nil {
+			return nil, err
+		}
+		added := n.DateAdded
+		if added == "" || added == "0" {
+			added = now
+		}
+		node := &Node{
+			ID:        strconv.FormatInt(nextID, 10),
+			GUID:      guid,
+			Name:      n.Name,
+			Type:      n.Type,
+			URL:       n.URL,
+			DateAdded: added,
+		}
+		if node.IsFolder() {
+			node.DateModified = now
+			node.Children = []*Node{}
+		}
+		nextID++
+		return node, nil
+	}
+
+	var merge func(into, from *Node) error
+	merge = func(into, from *Node) error {
+		for _, child := range from.Children {
+			if child.IsFolder() {
+				var folder *Node
+				for _, c := range into.Children {
+					if c.IsFolder() && c.Name == child.Name {
+						folder = c
+						break
+					}
+				}
+				if folder == nil {
+					var err error
+					if folder, err = newNode(child); err != nil {
+						return err
+					}
+					into.Children = append(into.Children, folder)
+				}
+				if err := merge(folder, child); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if existing[child.URL] {
+				result.Skipped++
+				continue
+			}
+			node, err := newNode(child)
+			if err != nil {
+				return err
+			}
+			existing[child.URL] = true
+			into.Children = append(into.Children, node)
+			result.Added++
+		}
+		return nil
+	}
+
+	// Create the target folder below the root with IDs and GUIDs
+	target := root
+	for _, name := range names[1:] {
+		target = ensureFolder(target, []string{name})
+		if target.ID == "" {
+			folder, err := newNode(&Node{Type: TypeFolder, Name: name})
+			if err != nil {
+				return result, err
+			}
+			*target = *folder
+		}
+	}
+
+	if err := merge(target, src); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// NewGUID returns a random version 4 UUID, the form of Chrome's GUIDs
+func NewGUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate GUID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
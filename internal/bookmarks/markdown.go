@@ -0,0 +1,162 @@
+package bookmarks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// markdownIndex is the file listing the root folders in a markdown tree,
+// shown by GitHub when browsing the directory
+const markdownIndex = "README.md"
+
+// WriteMarkdown writes bookmarks as a single markdown document with a
+// heading per folder and a list of links under each
+func WriteMarkdown(f *File, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Bookmarks\n")
+
+	for _, root := range f.Roots.Nodes() {
+		if len(root.Children) == 0 {
+			continue
+		}
+		root.Walk(func(n *Node, parents []*Node) error {
+			if !n.IsFolder() {
+				return nil
+			}
+
+			// Markdown has six heading levels, deeper folders show their path
+			level, title := len(parents)+2, n.Name
+			if level > 6 {
+				path := append(parents[1:len(parents):len(parents)], n)
+				level, title = 6, FolderPath(path)
+			}
+			fmt.Fprintf(bw, "\n%s %s\n", strings.Repeat("#", level), escapeMarkdown(title))
+
+			if links := markdownLinks(n); links != "" {
+				bw.WriteString("\n" + links)
+			}
+			return nil
+		})
+	}
+
+	return bw.Flush()
+}
+
+// WriteMarkdownTree writes one markdown file per folder below dir, e.g.
+// "Bookmarks bar/Work/Infra.md", with a README.md index of the root folders
+func WriteMarkdownTree(f *File, dir string, write WriteFileFunc) error {
+	var index strings.Builder
+	index.WriteString("# Bookmarks\n\n")
+
+	names := make(map[string]bool)
+	for _, root := range f.Roots.Nodes() {
+		if len(root.Children) == 0 {
+			continue
+		}
+		name := uniqueFileName(names, root.Name)
+		fmt.Fprintf(&index, "- [%s](%s)\n", escapeMarkdown(root.Name), markdownPath(name+".md"))
+		if err := writeMarkdownFolder(root, nil, dir, name, write); err != nil {
+			return err
+		}
+	}
+
+	return write(filepath.Join(dir, markdownIndex), []byte(index.String()))
+}
+
+// writeMarkdownFolder writes dir/name.md for a folder and the files of its
+// subfolders to dir/name/
+func writeMarkdownFolder(n *Node, parents []*Node, dir, name string, write WriteFileFunc) error {
+	path := append(parents[:len(parents):len(parents)], n)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", escapeMarkdown(FolderPath(path)))
+
+	names := make(map[string]bool)
+	var subfolders []string
+	for _, child := range n.Children {
+		if !child.IsFolder() {
+			continue
+		}
+		childName := uniqueFileName(names, child.Name)
+		subfolders = append(subfolders, fmt.Sprintf("- 📁 [%s](%s)\n",
+			escapeMarkdown(child.Name), markdownPath(name+"/"+childName+".md")))
+		if err := writeMarkdownFolder(child, path, filepath.Join(dir, name), childName, write); err != nil {
+			return err
+		}
+	}
+	if len(subfolders) > 0 {
+		b.WriteString("\n" + strings.Join(subfolders, ""))
+	}
+
+	if links := markdownLinks(n); links != "" {
+		b.WriteString("\n" + links)
+	}
+
+	if err := write(filepath.Join(dir, name+".md"), []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name+".md", err)
+	}
+	return nil
+}
+
+// markdownLinks lists the bookmarks directly inside a folder
+func markdownLinks(n *Node) string {
+	var b strings.Builder
+	for _, child := range n.Children {
+		if !child.IsURL() {
+			continue
+		}
+		title := child.Name
+		if title == "" {
+			title = child.URL
+		}
+		fmt.Fprintf(&b, "- [%s](%s)", escapeMarkdown(title), escapeMarkdownURL(child.URL))
+		if added := child.Added(); !added.IsZero() {
+			fmt.Fprintf(&b, " — added %s", added.Format("2006-01-02"))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// uniqueFileName returns a file name for a folder that doesn't clash with
+// its siblings, appending a number to repeated names
+func uniqueFileName(seen map[string]bool, name string) string {
+	base := SafePathName(name)
+	if base == "" || strings.EqualFold(base+".md", markdownIndex) {
+		base = "_" + base
+	}
+
+	unique := base
+	for i := 2; seen[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s (%d)", base, i)
+	}
+	seen[strings.ToLower(unique)] = true
+	return unique
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;", ">", "&gt;",
+)
+
+// escapeMarkdown escapes characters that would otherwise format text
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// escapeMarkdownURL escapes characters that would end a link destination
+func escapeMarkdownURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E").Replace(u)
+}
+
+// markdownPath escapes a relative file path for use as a link
+func markdownPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
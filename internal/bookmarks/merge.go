@@ -0,0 +1,481 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Conflict fields
+const (
+	ConflictName    = "name"
+	ConflictURL     = "url"
+	ConflictFolder  = "folder"
+	ConflictDeleted = "deleted"
+)
+
+// Conflict is a change made differently on both sides of a merge. The local
+// change wins, except that nodes changed on one side are kept even if the
+// other side deleted them.
+type Conflict struct {
+	GUID   string `json:"guid,omitempty"`
+	Name   string `json:"name"`
+	Folder string `json:"folder"`
+	Field  string `json:"field"`
+	Ours   string `json:"ours"`
+	Theirs string `json:"theirs"`
+}
+
+// String returns a one-line description of the conflict and how it was
+// resolved
+func (c Conflict) String() string {
+	if c.Field == ConflictDeleted {
+		return fmt.Sprintf("%s: %q was %s locally and %s remotely, kept it", c.Folder, c.Name, c.Ours, c.Theirs)
+	}
+	return fmt.Sprintf("%s: %s of %q changed to %q locally and %q remotely, kept %q", c.Folder, c.Field, c.Name, c.Ours, c.Theirs, c.Ours)
+}
+
+// rootKeys identify the permanent folders, which are matched by position
+// rather than GUID
+var rootKeys = []string{"root:bookmark_bar", "root:other", "root:synced"}
+
+// mergeSide indexes one version of the tree for a merge
+type mergeSide struct {
+	nodes    map[string]*Node    // node key to node
+	parent   map[string]string   // node key to the key of its folder
+	children map[string][]string // folder key to child keys in order
+	order    []string            // node keys in tree order
+	roots    map[string]*Node    // root key to permanent folder
+}
+
+func indexMergeSide(f *File) *mergeSide {
+	side := &mergeSide{
+		nodes:    make(map[string]*Node),
+		parent:   make(map[string]string),
+		children: make(map[string][]string),
+		roots:    make(map[string]*Node),
+	}
+	if f == nil {
+		return side
+	}
+
+	roots := []*Node{f.Roots.BookmarkBar, f.Roots.Other, f.Roots.Synced}
+	for i, root := range roots {
+		if root != nil {
+			side.roots[rootKeys[i]] = root
+			side.index(rootKeys[i], root.Children)
+		}
+	}
+	return side
+}
+
+func (side *mergeSide) index(parentKey string, children []*Node) {
+	for _, child := range children {
+		key := nodeKey(child)
+		if _, dup := side.nodes[key]; dup {
+			continue
+		}
+		side.nodes[key] = child
+		side.parent[key] = parentKey
+		side.children[parentKey] = append(side.children[parentKey], key)
+		side.order = append(side.order, key)
+		side.index(key, child.Children)
+	}
+}
+
+// merger holds the state of a three-way merge
+type merger struct {
+	base, ours, theirs *mergeSide
+
+	nodes        map[string]*Node  // merged nodes without children
+	parent       map[string]string // merged folder of each node
+	conflicts    []Conflict
+	conflictKeys []string // node key of each conflict
+}
+
+// Merge combines the changes made in ours and theirs since their common
+// ancestor base. Nodes are matched by GUID, and each node's name, URL and
+// folder are merged separately, so e.g. a bookmark renamed on one side and
+// moved on the other is both renamed and moved. Any of the files may be nil
+// to represent an empty tree. File level fields are taken from ours.
+func Merge(base, ours, theirs *File) (*File, []Conflict, error) {
+	if ours == nil {
+		ours = theirs
+	}
+	if ours == nil {
+		return nil, nil, fmt.Errorf("nothing to merge")
+	}
+
+	m := &merger{
+		base:   indexMergeSide(base),
+		ours:   indexMergeSide(ours),
+		theirs: indexMergeSide(theirs),
+		nodes:  make(map[string]*Node),
+		parent: make(map[string]string),
+	}
+
+	for _, key := range m.keys() {
+		m.mergeNode(key)
+	}
+	m.reviveParents()
+	m.breakCycles()
+
+	merged, err := ours.Clone()
+	if err != nil {
+		return nil, nil, err
+	}
+	roots := []*Node{merged.Roots.BookmarkBar, merged.Roots.Other, merged.Roots.Synced}
+	for i, root := range roots {
+		if root != nil {
+			root.Children = m.build(rootKeys[i])
+		}
+	}
+	m.renumber(merged)
+
+	return merged, m.resolveConflicts(merged), nil
+}
+
+// keys returns every node key of the three versions, in local tree order
+// first
+func (m *merger) keys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, side := range []*mergeSide{m.ours, m.theirs, m.base} {
+		for _, key := range side.order {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// mergeNode decides whether the node survives the merge and merges its
+// fields and folder
+func (m *merger) mergeNode(key string) {
+	b, o, t := m.base.nodes[key], m.ours.nodes[key], m.theirs.nodes[key]
+
+	switch {
+	case o != nil && t != nil:
+		m.nodes[key] = m.mergeFields(key, b, o, t)
+		m.parent[key] = m.merge3(key, ConflictFolder, b != nil, m.base.parent[key], m.ours.parent[key], m.theirs.parent[key])
+
+	case o != nil && b == nil:
+		m.keep(key, o, m.ours.parent[key]) // added locally
+
+	case t != nil && b == nil:
+		m.keep(key, t, m.theirs.parent[key]) // added remotely
+
+	case o != nil:
+		// Deleted remotely, kept if it was changed locally
+		if m.changed(key, b, o, m.ours) {
+			m.keep(key, o, m.ours.parent[key])
+			m.addConflict(key, Conflict{Name: o.Name, Field: ConflictDeleted, Ours: "changed", Theirs: "deleted"})
+		}
+
+	case t != nil:
+		// Deleted locally, kept if it was changed remotely
+		if m.changed(key, b, t, m.theirs) {
+			m.keep(key, t, m.theirs.parent[key])
+			m.addConflict(key, Conflict{Name: t.Name, Field: ConflictDeleted, Ours: "deleted", Theirs: "changed"})
+		}
+	}
+}
+
+func (m *merger) keep(key string, n *Node, parent string) {
+	m.nodes[key] = shallowCopy(n)
+	m.parent[key] = parent
+}
+
+// changed reports whether the node's name, URL or folder differs from base
+func (m *merger) changed(key string, b, n *Node, side *mergeSide) bool {
+	return b.Name != n.Name || b.URL != n.URL || m.base.parent[key] != side.parent[key]
+}
+
+// mergeFields merges a node present on both sides. Fields other than the
+// name and URL, e.g. dates and meta_info, are taken from the side that
+// changed them, preferring ours.
+func (m *merger) mergeFields(key string, b, o, t *Node) *Node {
+	merged := shallowCopy(o)
+	if b != nil && nodeProperties(o) == nodeProperties(b) {
+		merged = shallowCopy(t)
+	}
+
+	name, url := "", ""
+	if b != nil {
+		name, url = b.Name, b.URL
+	}
+	merged.Name = m.merge3(key, ConflictName, b != nil, name, o.Name, t.Name)
+	merged.URL = m.merge3(key, ConflictURL, b != nil, url, o.URL, t.URL)
+	return merged
+}
+
+// merge3 merges a single value, recording a conflict and keeping ours if
+// both sides changed it differently
+func (m *merger) merge3(key, field string, hasBase bool, base, ours, theirs string) string {
+	switch {
+	case ours == theirs:
+		return ours
+	case hasBase && ours == base:
+		return theirs
+	case hasBase && theirs == base:
+		return ours
+	}
+
+	c := Conflict{Field: field, Ours: ours, Theirs: theirs}
+	if field == ConflictName && hasBase {
+		c.Name = base
+	}
+	if field == ConflictFolder {
+		c.Ours, c.Theirs = m.folderName(ours), m.folderName(theirs)
+	}
+	m.addConflict(key, c)
+	return ours
+}
+
+func (m *merger) addConflict(key string, c Conflict) {
+	m.conflicts = append(m.conflicts, c)
+	m.conflictKeys = append(m.conflictKeys, key)
+}
+
+// folderName returns the name of a folder from whichever version has it
+func (m *merger) folderName(key string) string {
+	for _, side := range []*mergeSide{m.ours, m.theirs, m.base} {
+		if n, ok := side.nodes[key]; ok {
+			return n.Name
+		}
+		if n, ok := side.roots[key]; ok {
+			return n.Name
+		}
+	}
+	return key
+}
+
+// reviveParents keeps folders deleted on one side when the other side added
+// or moved nodes into them
+func (m *merger) reviveParents() {
+	for _, key := range m.keys() {
+		for {
+			if _, ok := m.nodes[key]; !ok {
+				break
+			}
+			parent := m.parent[key]
+			if _, ok := m.nodes[parent]; ok || isRootKey(parent) {
+				break
+			}
+
+			// Deleted on the side that doesn't have it
+			c := Conflict{Field: ConflictDeleted, Ours: "deleted", Theirs: "changed"}
+			side := m.theirs
+			n, ok := side.nodes[parent]
+			if !ok {
+				c.Ours, c.Theirs = "changed", "deleted"
+				side = m.ours
+				n, ok = side.nodes[parent]
+			}
+			if !ok {
+				c.Theirs = "deleted"
+				side = m.base
+				n, ok = side.nodes[parent]
+			}
+			if !ok {
+				m.parent[key] = rootKeys[1]
+				break
+			}
+
+			m.keep(parent, n, side.parent[parent])
+			c.Name = n.Name
+			m.addConflict(parent, c)
+			key = parent
+		}
+	}
+}
+
+// breakCycles undoes moves that put a folder inside itself, which happens
+// when two folders were moved into each other on different sides
+func (m *merger) breakCycles() {
+	for _, key := range m.keys() {
+		seen := make(map[string]bool)
+		for k := key; ; k = m.parent[k] {
+			if _, ok := m.nodes[k]; !ok {
+				break
+			}
+			if !seen[k] {
+				seen[k] = true
+				continue
+			}
+
+			// Back to the local folder, or to Other bookmarks if that's
+			// where it already was
+			parent := m.ours.parent[k]
+			if parent == m.parent[k] || parent == "" {
+				parent = rootKeys[1]
+			}
+			m.addConflict(k, Conflict{
+				Field:  ConflictFolder,
+				Ours:   m.folderName(parent),
+				Theirs: m.folderName(m.theirs.parent[k]),
+			})
+			m.parent[k] = parent
+			seen = make(map[string]bool)
+			k = key
+			seen[k] = true
+		}
+	}
+}
+
+// build returns the merged children of a folder, an empty slice if it has
+// none, since Chrome rejects folders without a children array
+func (m *merger) build(parentKey string) []*Node {
+	keys := m.childOrder(parentKey)
+	children := make([]*Node, 0, len(keys))
+	for _, key := range keys {
+		n := m.nodes[key]
+		if n.IsFolder() {
+			n.Children = m.build(key)
+		}
+		children = append(children, n)
+	}
+	return children
+}
+
+// childOrder orders a folder's merged children. The order of the side that
+// reordered them is used, and nodes new to the folder are placed after
+// their predecessor on the side that added them.
+func (m *merger) childOrder(parentKey string) []string {
+	in := func(order []string) []string {
+		var keys []string
+		for _, key := range order {
+			if _, ok := m.nodes[key]; ok && m.parent[key] == parentKey {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+	base := in(m.base.children[parentKey])
+	ours := in(m.ours.children[parentKey])
+	theirs := in(m.theirs.children[parentKey])
+
+	order, other := ours, theirs
+	if equalKeys(ours, base) {
+		order, other = theirs, ours
+	}
+
+	placed := make(map[string]bool)
+	for _, key := range order {
+		placed[key] = true
+	}
+	for i, key := range other {
+		if placed[key] {
+			continue
+		}
+		at := 0
+		for j := i - 1; j >= 0; j-- {
+			if pos := indexOf(order, other[j]); pos >= 0 {
+				at = pos + 1
+				break
+			}
+		}
+		order = append(order[:at], append([]string{key}, order[at:]...)...)
+		placed[key] = true
+	}
+
+	// Folders revived from base
+	for _, key := range m.keys() {
+		if !placed[key] && m.parent[key] == parentKey {
+			if _, ok := m.nodes[key]; ok {
+				order = append(order, key)
+				placed[key] = true
+			}
+		}
+	}
+	return order
+}
+
+// renumber gives nodes added remotely new IDs where they clash with local
+// ones, since both sides assign IDs independently
+func (m *merger) renumber(f *File) {
+	taken := make(map[string]bool)
+	f.Walk(func(n *Node, parents []*Node) error {
+		if len(parents) == 0 || m.ours.nodes[nodeKey(n)] != nil {
+			taken[n.ID] = true
+		}
+		return nil
+	})
+
+	next := f.MaxID() + 1
+	f.Walk(func(n *Node, parents []*Node) error {
+		if len(parents) == 0 || m.ours.nodes[nodeKey(n)] != nil {
+			return nil
+		}
+		if n.ID == "" || taken[n.ID] {
+			n.ID = strconv.FormatInt(next, 10)
+			next++
+		}
+		taken[n.ID] = true
+		return nil
+	})
+}
+
+// resolveConflicts fills in the folder of each conflict in the merged tree
+func (m *merger) resolveConflicts(f *File) []Conflict {
+	folders := make(map[string]string)
+	f.Walk(func(n *Node, parents []*Node) error {
+		folders[nodeKey(n)] = FolderPath(parents)
+		return nil
+	})
+
+	for i := range m.conflicts {
+		key := m.conflictKeys[i]
+		if n, ok := m.nodes[key]; ok {
+			m.conflicts[i].GUID = n.GUID
+			if m.conflicts[i].Name == "" {
+				m.conflicts[i].Name = n.Name
+			}
+		}
+		m.conflicts[i].Folder = folders[key]
+	}
+	return m.conflicts
+}
+
+// nodeProperties encodes the fields of a node other than its name, URL and
+// children for comparison
+func nodeProperties(n *Node) string {
+	c := shallowCopy(n)
+	c.Name, c.URL = "", ""
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+// shallowCopy copies a node without its children
+func shallowCopy(n *Node) *Node {
+	c := *n
+	c.Children = nil
+	return &c
+}
+
+func isRootKey(key string) bool {
+	return indexOf(rootKeys, key) >= 0
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func indexOf(keys []string, key string) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,247 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testURL(id, name string) *Node {
+	return &Node{ID: id, GUID: "guid-" + id, Name: name, Type: TypeURL, URL: "https://example.com/" + id}
+}
+
+func testFolder(id, name string, children ...*Node) *Node {
+	return &Node{ID: id, GUID: "guid-" + id, Name: name, Type: TypeFolder, Children: children}
+}
+
+func testFile(bar ...*Node) *File {
+	return &File{
+		Roots: Roots{
+			BookmarkBar: &Node{ID: "1", Name: "Bookmarks bar", Type: TypeFolder, Children: bar},
+			Other:       &Node{ID: "2", Name: "Other bookmarks", Type: TypeFolder},
+		},
+		Version: 1,
+	}
+}
+
+// outline lists the path of every node in tree order
+func outline(f *File) []string {
+	var paths []string
+	f.Walk(func(n *Node, parents []*Node) error {
+		if len(parents) > 0 {
+			paths = append(paths, FolderPath(parents)+"/"+n.Name)
+		}
+		return nil
+	})
+	return paths
+}
+
+func conflictFields(conflicts []Conflict) []string {
+	var fields []string
+	for _, c := range conflicts {
+		fields = append(fields, c.Field)
+	}
+	return fields
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      *File
+		ours      *File
+		theirs    *File
+		want      []string
+		conflicts []string
+	}{
+		{
+			name:   "rename against move",
+			base:   testFile(testURL("10", "A"), testFolder("20", "F")),
+			ours:   testFile(testURL("10", "A renamed"), testFolder("20", "F")),
+			theirs: testFile(testFolder("20", "F", testURL("10", "A"))),
+			want:   []string{"Bookmarks bar/F", "Bookmarks bar/F/A renamed"},
+		},
+		{
+			name:      "both renamed differently keeps ours",
+			base:      testFile(testURL("10", "A")),
+			ours:      testFile(testURL("10", "Ours")),
+			theirs:    testFile(testURL("10", "Theirs")),
+			want:      []string{"Bookmarks bar/Ours"},
+			conflicts: []string{ConflictName},
+		},
+		{
+			name:      "deleted locally, renamed remotely",
+			base:      testFile(testURL("10", "A"), testURL("11", "B")),
+			ours:      testFile(testURL("11", "B")),
+			theirs:    testFile(testURL("10", "A renamed"), testURL("11", "B")),
+			want:      []string{"Bookmarks bar/A renamed", "Bookmarks bar/B"},
+			conflicts: []string{ConflictDeleted},
+		},
+		{
+			name:      "renamed locally, deleted remotely",
+			base:      testFile(testURL("10", "A"), testURL("11", "B")),
+			ours:      testFile(testURL("10", "A renamed"), testURL("11", "B")),
+			theirs:    testFile(testURL("11", "B")),
+			want:      []string{"Bookmarks bar/A renamed", "Bookmarks bar/B"},
+			conflicts: []string{ConflictDeleted},
+		},
+		{
+			name:   "deleted and unchanged",
+			base:   testFile(testURL("10", "A"), testURL("11", "B")),
+			ours:   testFile(testURL("11", "B")),
+			theirs: testFile(testURL("10", "A"), testURL("11", "B")),
+			want:   []string{"Bookmarks bar/B"},
+		},
+		{
+			name:      "added into a folder deleted on the other side",
+			base:      testFile(testFolder("20", "F")),
+			ours:      testFile(),
+			theirs:    testFile(testFolder("20", "F", testURL("10", "New"))),
+			want:      []string{"Bookmarks bar/F", "Bookmarks bar/F/New"},
+			conflicts: []string{ConflictDeleted},
+		},
+		{
+			name:   "reordered locally, added remotely",
+			base:   testFile(testURL("10", "A"), testURL("11", "B"), testURL("12", "C")),
+			ours:   testFile(testURL("12", "C"), testURL("10", "A"), testURL("11", "B")),
+			theirs: testFile(testURL("10", "A"), testURL("11", "B"), testURL("13", "D"), testURL("12", "C")),
+			want:   []string{"Bookmarks bar/C", "Bookmarks bar/A", "Bookmarks bar/B", "Bookmarks bar/D"},
+		},
+		{
+			name:   "reordered remotely",
+			base:   testFile(testURL("10", "A"), testURL("11", "B")),
+			ours:   testFile(testURL("10", "A"), testURL("11", "B")),
+			theirs: testFile(testURL("11", "B"), testURL("10", "A")),
+			want:   []string{"Bookmarks bar/B", "Bookmarks bar/A"},
+		},
+		{
+			name:      "folders moved into each other",
+			base:      testFile(testFolder("20", "F1"), testFolder("21", "F2")),
+			ours:      testFile(testFolder("21", "F2", testFolder("20", "F1"))),
+			theirs:    testFile(testFolder("20", "F1", testFolder("21", "F2"))),
+			want:      []string{"Bookmarks bar/F2", "Bookmarks bar/F2/F1"},
+			conflicts: []string{ConflictFolder},
+		},
+		{
+			name:   "no base keeps both sides",
+			ours:   testFile(testURL("10", "A")),
+			theirs: testFile(testURL("11", "B"), testURL("12", "C")),
+			want:   []string{"Bookmarks bar/B", "Bookmarks bar/C", "Bookmarks bar/A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts, err := Merge(tt.base, tt.ours, tt.theirs)
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+			if got := outline(merged); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("merged tree:\n  got  %q\n  want %q", got, tt.want)
+			}
+			if got := conflictFields(conflicts); !reflect.DeepEqual(got, tt.conflicts) {
+				t.Errorf("conflicts: got %q, want %q (%v)", got, tt.conflicts, conflicts)
+			}
+		})
+	}
+}
+
+func TestMergeRenumbersRemoteIDs(t *testing.T) {
+	base := testFile(testURL("10", "A"))
+	ours := testFile(testURL("10", "A"), testURL("11", "Local"))
+	theirs := testFile(testURL("10", "A"), &Node{ID: "11", GUID: "guid-remote", Name: "Remote", Type: TypeURL, URL: "https://example.com/remote"})
+
+	merged, _, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	ids := make(map[string]string)
+	merged.Walk(func(n *Node, parents []*Node) error {
+		if other, ok := ids[n.ID]; ok {
+			t.Errorf("ID %s used by %q and %q", n.ID, other, n.Name)
+		}
+		ids[n.ID] = n.Name
+		return nil
+	})
+	if ids["11"] != "Local" {
+		t.Errorf("local node lost its ID, 11 is %q", ids["11"])
+	}
+	if ids["12"] != "Remote" {
+		t.Errorf("remote node wasn't given the next free ID: %v", ids)
+	}
+}
+
+func TestMergeKeepsChangedProperties(t *testing.T) {
+	base := testFile(testURL("10", "A"))
+	ours := testFile(testURL("10", "A renamed"))
+	theirs := testFile(testURL("10", "A"))
+	theirs.Roots.BookmarkBar.Children[0].MetaInfo = map[string]string{"note": "remote"}
+
+	merged, _, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	n := merged.Roots.BookmarkBar.Children[0]
+	if n.Name != "A renamed" || n.MetaInfo["note"] != "remote" {
+		t.Errorf("got name %q and meta_info %v, want the local name and the remote meta_info", n.Name, n.MetaInfo)
+	}
+	if !strings.HasPrefix(n.URL, "https://example.com/") {
+		t.Errorf("URL changed to %q", n.URL)
+	}
+}
+
+func TestMergeWritesEmptyFolders(t *testing.T) {
+	parse := func(data string) *File {
+		t.Helper()
+		f, err := Parse([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	base := parse(`{"roots": {
+		"bookmark_bar": {"id": "1", "name": "Bookmarks bar", "type": "folder", "children": [
+			{"id": "10", "guid": "guid-10", "name": "F", "type": "folder", "children": [
+				{"id": "11", "guid": "guid-11", "name": "A", "type": "url", "url": "https://example.com/11"}
+			]}
+		]},
+		"other": {"id": "2", "name": "Other bookmarks", "type": "folder", "children": []},
+		"synced": {"id": "3", "name": "Mobile bookmarks", "type": "folder", "children": []}
+	}, "version": 1}`)
+	ours := parse(`{"roots": {
+		"bookmark_bar": {"id": "1", "name": "Bookmarks bar", "type": "folder", "children": [
+			{"id": "10", "guid": "guid-10", "name": "F", "type": "folder", "children": []}
+		]},
+		"other": {"id": "2", "name": "Other bookmarks", "type": "folder", "children": []},
+		"synced": {"id": "3", "name": "Mobile bookmarks", "type": "folder", "children": []}
+	}, "version": 1}`)
+
+	merged, _, err := Merge(base, ours, base)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	data, err := merged.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Roots map[string]map[string]json.RawMessage `json:"roots"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range []string{"other", "synced"} {
+		if got := string(out.Roots[root]["children"]); got != "[]" {
+			t.Errorf("%s root children = %s, want []", root, got)
+		}
+	}
+	var bar []map[string]json.RawMessage
+	if err := json.Unmarshal(out.Roots["bookmark_bar"]["children"], &bar); err != nil {
+		t.Fatal(err)
+	}
+	if len(bar) != 1 || string(bar[0]["children"]) != "[]" {
+		t.Errorf("bookmark bar children = %s, want the folder with children []", out.Roots["bookmark_bar"]["children"])
+	}
+}
@@ -0,0 +1,426 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Node types used by Chrome
+const (
+	TypeURL    = "url"
+	TypeFolder = "folder"
+)
+
+// webkitEpochOffset is the number of microseconds between the WebKit epoch
+// (1601-01-01 UTC), which Chrome uses for its timestamps, and the Unix epoch
+const webkitEpochOffset = 11644473600000000
+
+// SkipChildren can be returned from a WalkFunc to skip the children of a folder
+var SkipChildren = errors.New("skip children")
+
+// File is the top level of a Chrome Bookmarks file
+type File struct {
+	Checksum     string
+	Roots        Roots
+	SyncMetadata string
+	Version      int
+
+	// Extra holds fields not covered by the model so they survive a round trip
+	Extra map[string]json.RawMessage
+}
+
+// Roots holds the permanent folders of a Chrome Bookmarks file
+type Roots struct {
+	BookmarkBar *Node
+	Other       *Node
+	Synced      *Node
+
+	// Extra holds roots not covered by the model so they survive a round trip
+	Extra map[string]json.RawMessage
+}
+
+// Node is a single bookmark or folder
+type Node struct {
+	ID           string
+	GUID         string
+	Name         string
+	Type         string
+	URL          string
+	DateAdded    string
+	DateModified string
+	DateLastUsed string
+	Children     []*Node
+	MetaInfo     map[string]string
+
+	// Extra holds fields not covered by the model so they survive a round trip
+	Extra map[string]json.RawMessage
+
+	// read has a bit per nodeFields entry that was in the decoded JSON, so
+	// that empty fields are written back exactly as they were read
+	read uint16
+}
+
+// nodeFields lists the JSON keys of the fields of Node
+var nodeFields = []string{"id", "guid", "name", "type", "url", "date_added", "date_modified", "date_last_used", "children", "meta_info"}
+
+// WalkFunc is called for every node visited by Walk. parents lists the
+// folders leading to the node, starting with its root.
+type WalkFunc func(n *Node, parents []*Node) error
+
+// Parse decodes the contents of a Chrome Bookmarks file
+func Parse(data []byte) (*File, error) {
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse bookmarks JSON: %w", err)
+	}
+	return &f, nil
+}
+
+// Load reads and decodes a Chrome Bookmarks file
+func Load(bookmarkPath string) (*File, error) {
+	data, err := os.ReadFile(bookmarkPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Format encodes the file as indented JSON with sorted keys for readable diffs
+func (f *File) Format() ([]byte, error) {
+	formatted, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format bookmarks: %w", err)
+	}
+	return formatted, nil
+}
+
+// Clone returns a deep copy of the bookmarks
+func (f *File) Clone() (*File, error) {
+	data, err := f.Format()
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Walk visits every node below the roots in depth-first order
+func (f *File) Walk(fn WalkFunc) error {
+	for _, root := range f.Roots.Nodes() {
+		if err := root.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bookmarks returns every URL node in the file in tree order
+func (f *File) Bookmarks() []*Node {
+	var nodes []*Node
+	f.Walk(func(n *Node, parents []*Node) error {
+		if n.IsURL() {
+			nodes = append(nodes, n)
+		}
+		return nil
+	})
+	return nodes
+}
+
+// FindByGUID returns the node with the given GUID, or nil if there is none
+func (f *File) FindByGUID(guid string) *Node {
+	var found *Node
+	f.Walk(func(n *Node, parents []*Node) error {
+		if found == nil && n.GUID == guid {
+			found = n
+		}
+		return nil
+	})
+	return found
+}
+
+// Nodes returns the known root folders in Chrome's order, skipping missing ones
+func (r *Roots) Nodes() []*Node {
+	var nodes []*Node
+	for _, n := range []*Node{r.BookmarkBar, r.Other, r.Synced} {
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// IsFolder reports whether the node is a folder
+func (n *Node) IsFolder() bool {
+	return n.Type == TypeFolder
+}
+
+// IsURL reports whether the node is a bookmark
+func (n *Node) IsURL() bool {
+	return n.Type == TypeURL
+}
+
+// Walk visits the node and all of its descendants in depth-first order
+func (n *Node) Walk(fn WalkFunc) error {
+	return n.walk(fn, nil)
+}
+
+func (n *Node) walk(fn WalkFunc, parents []*Node) error {
+	if err := fn(n, parents); err != nil {
+		if err == SkipChildren {
+			return nil
+		}
+		return err
+	}
+
+	// Copy so callbacks may keep the slice they were given
+	childParents := make([]*Node, len(parents)+1)
+	copy(childParents, parents)
+	childParents[len(parents)] = n
+
+	for _, child := range n.Children {
+		if err := child.walk(fn, childParents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Added returns the time the node was created
+func (n *Node) Added() time.Time {
+	return ParseTime(n.DateAdded)
+}
+
+// LastUsed returns the time the bookmark was last opened
+func (n *Node) LastUsed() time.Time {
+	return ParseTime(n.DateLastUsed)
+}
+
+// FolderPath joins the names of the given folders with slashes
+func FolderPath(parents []*Node) string {
+	names := make([]string, len(parents))
+	for i, p := range parents {
+		names[i] = p.Name
+	}
+	return strings.Join(names, "/")
+}
+
+// ParseTime converts a Chrome timestamp (microseconds since 1601-01-01 UTC)
+// to a time.Time. Empty or zero timestamps return the zero time.
+func ParseTime(s string) time.Time {
+	us, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || us == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(us - webkitEpochOffset).UTC()
+}
+
+// FormatTime converts a time.Time to a Chrome timestamp
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixMicro()+webkitEpochOffset, 10)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *File) UnmarshalJSON(data []byte) error {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+
+	*f = File{}
+	if err := takeField(fields, "checksum", &f.Checksum); err != nil {
+		return err
+	}
+	if err := takeField(fields, "roots", &f.Roots); err != nil {
+		return err
+	}
+	if err := takeField(fields, "sync_metadata", &f.SyncMetadata); err != nil {
+		return err
+	}
+	if err := takeField(fields, "version", &f.Version); err != nil {
+		return err
+	}
+	f.Extra = extraFields(fields)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (f *File) MarshalJSON() ([]byte, error) {
+	fields := copyFields(f.Extra)
+	if err := putField(fields, "checksum", f.Checksum, f.Checksum != ""); err != nil {
+		return nil, err
+	}
+	if err := putField(fields, "roots", &f.Roots, true); err != nil {
+		return nil, err
+	}
+	if err := putField(fields, "sync_metadata", f.SyncMetadata, f.SyncMetadata != ""); err != nil {
+		return nil, err
+	}
+	if err := putField(fields, "version", f.Version, true); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *Roots) UnmarshalJSON(data []byte) error {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+
+	*r = Roots{}
+	if err := takeField(fields, "bookmark_bar", &r.BookmarkBar); err != nil {
+		return err
+	}
+	if err := takeField(fields, "other", &r.Other); err != nil {
+		return err
+	}
+	if err := takeField(fields, "synced", &r.Synced); err != nil {
+		return err
+	}
+	r.Extra = extraFields(fields)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (r *Roots) MarshalJSON() ([]byte, error) {
+	fields := copyFields(r.Extra)
+	if err := putField(fields, "bookmark_bar", r.BookmarkBar, r.BookmarkBar != nil); err != nil {
+		return nil, err
+	}
+	if err := putField(fields, "other", r.Other, r.Other != nil); err != nil {
+		return nil, err
+	}
+	if err := putField(fields, "synced", r.Synced, r.Synced != nil); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (n *Node) UnmarshalJSON(data []byte) error {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+
+	*n = Node{}
+	for i, dst := range []interface{}{
+		&n.ID, &n.GUID, &n.Name, &n.Type, &n.URL,
+		&n.DateAdded, &n.DateModified, &n.DateLastUsed, &n.Children, &n.MetaInfo,
+	} {
+		key := nodeFields[i]
+		if _, ok := fields[key]; !ok {
+			continue
+		}
+		if err := takeField(fields, key, dst); err != nil {
+			return err
+		}
+		n.read |= 1 << i
+	}
+	n.Extra = extraFields(fields)
+	return nil
+}
+
+// unsetField records that a field was removed, so that it isn't written
+// even though it was read
+func (n *Node) unsetField(key string) {
+	for i, field := range nodeFields {
+		if field == key {
+			n.read &^= 1 << i
+		}
+	}
+}
+
+// MarshalJSON implements json.Marshaler. Fields that were read are written
+// even if empty, other fields only if set. Nodes that weren't decoded always
+// get an id, name and type. Folders always get a children array, Chrome
+// rejects the file otherwise.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	decoded := n.read != 0
+	children := n.Children
+	if children == nil && n.IsFolder() {
+		children = []*Node{}
+	}
+
+	fields := copyFields(n.Extra)
+	for i, field := range []struct {
+		value interface{}
+		set   bool
+	}{
+		{n.ID, n.ID != "" || !decoded},
+		{n.GUID, n.GUID != ""},
+		{n.Name, n.Name != "" || !decoded},
+		{n.Type, n.Type != "" || !decoded},
+		{n.URL, n.URL != ""},
+		{n.DateAdded, n.DateAdded != ""},
+		{n.DateModified, n.DateModified != ""},
+		{n.DateLastUsed, n.DateLastUsed != ""},
+		{children, children != nil},
+		{n.MetaInfo, n.MetaInfo != nil},
+	} {
+		present := field.set || n.read&(1<<i) != 0
+		if err := putField(fields, nodeFields[i], field.value, present); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// decodeFields splits a JSON object into its raw fields
+func decodeFields(data []byte) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// takeField decodes and removes a known field, leaving dst untouched if absent
+func takeField(fields map[string]json.RawMessage, key string, dst interface{}) error {
+	raw, ok := fields[key]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return fmt.Errorf("invalid %q field: %w", key, err)
+	}
+	delete(fields, key)
+	return nil
+}
+
+// putField encodes a known field if it is present
+func putField(fields map[string]json.RawMessage, key string, value interface{}, present bool) error {
+	if !present {
+		return nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %q field: %w", key, err)
+	}
+	fields[key] = raw
+	return nil
+}
+
+// extraFields returns the fields left after the known ones were taken
+func extraFields(fields map[string]json.RawMessage) map[string]json.RawMessage {
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+func copyFields(extra map[string]json.RawMessage) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage, len(extra)+10)
+	for k, v := range extra {
+		fields[k] = v
+	}
+	return fields
+}
@@ -0,0 +1,63 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("testdata/Bookmarks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	formatted, err := f.Format()
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+
+	var want, got interface{}
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(formatted, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the file:\n%s", formatted)
+	}
+}
+
+func TestMarshalNewNode(t *testing.T) {
+	data, err := json.Marshal(&Node{Type: TypeFolder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"children":[],"id":"","name":"","type":"folder"}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
+
+func TestMarshalFolderWithoutChildren(t *testing.T) {
+	for _, input := range []string{
+		`{"id":"10","name":"F","type":"folder"}`,
+		`{"children":null,"id":"10","name":"F","type":"folder"}`,
+	} {
+		var n Node
+		if err := json.Unmarshal([]byte(input), &n); err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"children":[],"id":"10","name":"F","type":"folder"}`; string(data) != want {
+			t.Errorf("%s: got %s, want %s", input, data, want)
+		}
+	}
+}
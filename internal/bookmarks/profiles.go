@@ -0,0 +1,146 @@
+package bookmarks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Profile is a browser profile found in the user data directory
+type Profile struct {
+	Browser      *Browser
+	Dir          string // Directory name, e.g. "Default" or "Profile 1"
+	Name         string // Display name shown in the browser, e.g. "Work"
+	BookmarkPath string // Bookmarks file, or the bookmarkbackups directory for Firefox
+	HasBookmarks bool   // Whether the bookmarks can be read
+}
+
+// localState is the part of Chrome's "Local State" file listing profiles
+type localState struct {
+	Profile struct {
+		InfoCache map[string]struct {
+			Name string `json:"name"`
+		} `json:"info_cache"`
+	} `json:"profile"`
+}
+
+// ListProfiles returns the profiles listed in the "Local State" file of the
+// browser's user data directory, sorted by directory name. If there is no
+// Local State file only the default profile is returned.
+func (b *Browser) ListProfiles() ([]Profile, error) {
+	userDataDir, err := b.UserDataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Format == FormatFirefox {
+		return firefoxProfiles(b, userDataDir)
+	}
+
+	if b.SingleProfile {
+		bookmarkPath := filepath.Join(userDataDir, "Bookmarks")
+		_, statErr := os.Stat(bookmarkPath)
+		return []Profile{{
+			Browser:      b,
+			Dir:          "",
+			Name:         DefaultProfile,
+			BookmarkPath: bookmarkPath,
+			HasBookmarks: statErr == nil,
+		}}, nil
+	}
+
+	names := map[string]string{DefaultProfile: DefaultProfile}
+
+	data, err := os.ReadFile(filepath.Join(userDataDir, "Local State"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read Local State: %w", err)
+	}
+	if err == nil {
+		var state localState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse Local State: %w", err)
+		}
+		if len(state.Profile.InfoCache) > 0 {
+			names = make(map[string]string)
+			for dir, info := range state.Profile.InfoCache {
+				names[dir] = info.Name
+			}
+		}
+	}
+
+	profiles := make([]Profile, 0, len(names))
+	for dir, name := range names {
+		if name == "" {
+			name = dir
+		}
+		bookmarkPath := filepath.Join(userDataDir, dir, "Bookmarks")
+		_, statErr := os.Stat(bookmarkPath)
+		profiles = append(profiles, Profile{
+			Browser:      b,
+			Dir:          dir,
+			Name:         name,
+			BookmarkPath: bookmarkPath,
+			HasBookmarks: statErr == nil,
+		})
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Dir < profiles[j].Dir
+	})
+	return profiles, nil
+}
+
+// FindProfile returns the profile whose display name or directory name
+// matches name (case-insensitively)
+func FindProfile(profiles []Profile, name string) (Profile, error) {
+	for _, p := range profiles {
+		if strings.EqualFold(p.Name, name) || strings.EqualFold(p.Dir, name) {
+			return p, nil
+		}
+	}
+
+	known := make([]string, len(profiles))
+	for i, p := range profiles {
+		known[i] = fmt.Sprintf("%q", p.Name)
+	}
+	return Profile{}, fmt.Errorf("profile %q not found (available: %s)", name, strings.Join(known, ", "))
+}
+
+// Load reads the profile's bookmarks
+func (p Profile) Load() (*File, error) {
+	if p.Browser != nil && p.Browser.Format == FormatFirefox {
+		return LoadFirefoxBackups(p.BookmarkPath)
+	}
+	return Load(p.BookmarkPath)
+}
+
+// SafeName returns the profile's display name made safe for use as a
+// directory name in the repository
+func (p Profile) SafeName() string {
+	if name := SafePathName(p.Name); name != "" {
+		return name
+	}
+	return p.SafeDir()
+}
+
+// SafeDir returns the profile's directory name made safe for use as a
+// directory name in the repository
+func (p Profile) SafeDir() string {
+	return SafePathName(p.Dir)
+}
+
+// SafePathName replaces the characters that aren't allowed in file names on
+// some platforms
+func SafePathName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, name)
+
+	return strings.Trim(name, " .")
+}
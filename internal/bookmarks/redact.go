@@ -0,0 +1,213 @@
+package bookmarks
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultPlaceholder replaces redacted secrets
+const DefaultPlaceholder = "REDACTED"
+
+// secretParams are query parameters that commonly carry credentials, such as
+// OAuth tokens, API keys and the signatures of pre-signed S3 and GCS URLs
+var secretParams = []string{
+	"access_token", "id_token", "refresh_token", "token", "auth", "auth_token",
+	"api_key", "apikey", "secret", "client_secret", "password", "passwd", "pwd",
+	"session", "sessionid", "session_id", "sid", "sig", "signature",
+	"x-amz-signature", "x-amz-credential", "x-amz-security-token", "awsaccesskeyid",
+	"x-goog-signature", "x-goog-credential",
+}
+
+// secretPatterns find credentials anywhere in a URL
+var secretPatterns = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"JWT", regexp.MustCompile(`eyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`)},
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+}
+
+// RedactOptions configures a Redactor
+type RedactOptions struct {
+	DisableBuiltin bool     // Only use Params and Patterns
+	Placeholder    string   // Replaces secrets, DefaultPlaceholder if empty
+	Params         []string // Additional query parameter names to redact
+	Patterns       []string // Regular expressions redacted in URLs and titles
+}
+
+// Redactor replaces secrets in bookmark URLs and titles with a placeholder
+type Redactor struct {
+	placeholder string
+	builtin     bool
+	params      map[string]bool
+	patterns    []*regexp.Regexp
+}
+
+// Secret is a secret found in a bookmark
+type Secret struct {
+	Node     *Node
+	Folder   string
+	Rule     string // What matched, e.g. "parameter access_token, JWT"
+	Redacted string // The URL with secrets replaced
+}
+
+// String describes the bookmark and rules without revealing the secret
+func (s Secret) String() string {
+	return fmt.Sprintf("%s: %s <%s>  (%s)", s.Folder, s.Node.Name, s.Redacted, s.Rule)
+}
+
+// NewRedactor compiles redaction options
+func NewRedactor(opts RedactOptions) (*Redactor, error) {
+	r := &Redactor{
+		placeholder: opts.Placeholder,
+		builtin:     !opts.DisableBuiltin,
+		params:      make(map[string]bool),
+	}
+	if r.placeholder == "" {
+		r.placeholder = DefaultPlaceholder
+	}
+	if r.builtin {
+		for _, p := range secretParams {
+			r.params[p] = true
+		}
+	}
+	for _, p := range opts.Params {
+		r.params[strings.ToLower(p)] = true
+	}
+	for _, p := range opts.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad redaction pattern: %w", err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Apply returns a copy of the bookmarks with secrets replaced, and the
+// secrets that were found
+func (r *Redactor) Apply(f *File) (*File, []Secret, error) {
+	redacted, err := f.Clone()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var secrets []Secret
+	redacted.Walk(func(n *Node, parents []*Node) error {
+		if !n.IsURL() {
+			return nil
+		}
+		original := *n
+		if rules := r.redactNode(n); len(rules) > 0 {
+			secrets = append(secrets, Secret{
+				Node:     &original,
+				Folder:   FolderPath(parents),
+				Rule:     strings.Join(rules, ", "),
+				Redacted: n.URL,
+			})
+		}
+		return nil
+	})
+	return redacted, secrets, nil
+}
+
+// Scan returns the secrets in the bookmarks without modifying them
+func (r *Redactor) Scan(f *File) ([]Secret, error) {
+	_, secrets, err := r.Apply(f)
+	return secrets, err
+}
+
+// redactNode redacts a bookmark's URL and title, returning the rules that
+// matched
+func (r *Redactor) redactNode(n *Node) []string {
+	var rules []string
+	n.URL, rules = r.RedactURL(n.URL)
+
+	for _, re := range r.patterns {
+		if re.MatchString(n.Name) {
+			n.Name = re.ReplaceAllLiteralString(n.Name, r.placeholder)
+			rules = append(rules, fmt.Sprintf("pattern %q in title", re))
+		}
+	}
+	return rules
+}
+
+// RedactURL replaces secrets in a URL, returning the redacted URL and the
+// rules that matched
+func (r *Redactor) RedactURL(rawURL string) (string, []string) {
+	var rules []string
+	redacted := rawURL
+
+	if u, err := url.Parse(rawURL); err == nil {
+		changed := false
+		if _, hasPassword := u.User.Password(); hasPassword && r.builtin {
+			u.User = url.UserPassword(u.User.Username(), r.placeholder)
+			rules = append(rules, "password in URL")
+			changed = true
+		}
+		if query, matched := r.redactParams(u.RawQuery); len(matched) > 0 {
+			u.RawQuery = query
+			rules = append(rules, matched...)
+			changed = true
+		}
+		// OAuth implicit flows return tokens in the fragment
+		if strings.Contains(u.Fragment, "=") {
+			if fragment, matched := r.redactParams(u.EscapedFragment()); len(matched) > 0 {
+				u.Fragment, u.RawFragment = "", ""
+				if unescaped, err := url.PathUnescape(fragment); err == nil {
+					u.Fragment, u.RawFragment = unescaped, fragment
+				}
+				rules = append(rules, matched...)
+				changed = true
+			}
+		}
+		if changed {
+			redacted = u.String()
+		}
+	}
+
+	if r.builtin {
+		for _, p := range secretPatterns {
+			if p.re.MatchString(redacted) {
+				redacted = p.re.ReplaceAllLiteralString(redacted, r.placeholder)
+				rules = append(rules, p.name)
+			}
+		}
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(redacted) {
+			redacted = re.ReplaceAllLiteralString(redacted, r.placeholder)
+			rules = append(rules, fmt.Sprintf("pattern %q", re))
+		}
+	}
+
+	return redacted, rules
+}
+
+// redactParams replaces the values of secret parameters in a query string,
+// keeping the order and encoding of the others
+func (r *Redactor) redactParams(query string) (string, []string) {
+	if query == "" {
+		return query, nil
+	}
+
+	var rules []string
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		key, value, hasValue := strings.Cut(param, "=")
+		if !hasValue || value == "" || value == r.placeholder {
+			continue
+		}
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if r.params[strings.ToLower(name)] {
+			params[i] = key + "=" + r.placeholder
+			rules = append(rules, "parameter "+name)
+		}
+	}
+	return strings.Join(params, "&"), rules
+}
@@ -0,0 +1,122 @@
+package bookmarks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FindFolder returns the folder at a slash-separated path starting with the
+// root folder name, e.g. "Bookmarks bar/Work/Infra", together with its
+// parents. It returns nil if there is no such folder.
+func (f *File) FindFolder(path string) (*Node, []*Node) {
+	path = strings.Trim(path, "/")
+
+	var found *Node
+	var foundParents []*Node
+	f.Walk(func(n *Node, parents []*Node) error {
+		if found != nil || !n.IsFolder() {
+			return SkipChildren
+		}
+		nodePath := FolderPath(append(parents, n))
+		if nodePath == path {
+			found, foundParents = n, parents
+			return SkipChildren
+		}
+		if !strings.HasPrefix(path, nodePath+"/") {
+			return SkipChildren
+		}
+		return nil
+	})
+
+	return found, foundParents
+}
+
+// RestoreFolder replaces the folder at path with its version from src. The
+// folder is matched by GUID first so it is found even if it was renamed or
+// moved since; if it no longer exists it is re-created in its old parent.
+// Restored nodes whose IDs are now taken by other nodes get new IDs.
+func (f *File) RestoreFolder(src *File, path string) error {
+	restored, srcParents := src.FindFolder(path)
+	if restored == nil {
+		return fmt.Errorf("folder %q not found in the restored version", path)
+	}
+	if len(srcParents) == 0 {
+		// A root folder: restore its contents into the current root
+		current, _ := f.FindFolder(path)
+		if current == nil {
+			return fmt.Errorf("root folder %q not found", path)
+		}
+		current.Children = restored.Children
+		f.reassignDuplicateIDs(restored)
+		return nil
+	}
+
+	replaced := false
+	if restored.GUID != "" {
+		f.Walk(func(n *Node, parents []*Node) error {
+			for i, child := range n.Children {
+				if !replaced && child.GUID == restored.GUID {
+					n.Children[i] = restored
+					replaced = true
+				}
+			}
+			return nil
+		})
+	}
+
+	if !replaced {
+		parentPath := FolderPath(srcParents)
+		parent, _ := f.FindFolder(parentPath)
+		if parent == nil {
+			return fmt.Errorf("parent folder %q not found in current bookmarks", parentPath)
+		}
+		parent.Children = append(parent.Children, restored)
+	}
+
+	f.reassignDuplicateIDs(restored)
+	return nil
+}
+
+// MaxID returns the largest numeric node ID in the file
+func (f *File) MaxID() int64 {
+	var max int64
+	f.Walk(func(n *Node, parents []*Node) error {
+		if id, err := strconv.ParseInt(n.ID, 10, 64); err == nil && id > max {
+			max = id
+		}
+		return nil
+	})
+	return max
+}
+
+// reassignDuplicateIDs gives nodes below sub new IDs where their ID is also
+// used by a node outside of sub
+func (f *File) reassignDuplicateIDs(sub *Node) {
+	inside := make(map[*Node]bool)
+	sub.Walk(func(n *Node, parents []*Node) error {
+		inside[n] = true
+		return nil
+	})
+
+	taken := make(map[string]bool)
+	f.Walk(func(n *Node, parents []*Node) error {
+		if n == sub {
+			return SkipChildren
+		}
+		if !inside[n] {
+			taken[n.ID] = true
+		}
+		return nil
+	})
+
+	next := f.MaxID() + 1
+	sub.Walk(func(n *Node, parents []*Node) error {
+		if taken[n.ID] {
+			n.ID = strconv.FormatInt(next, 10)
+			next++
+		}
+		taken[n.ID] = true
+		return nil
+	})
+}
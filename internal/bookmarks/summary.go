@@ -0,0 +1,124 @@
+package bookmarks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Count returns the number of changes of the given kind
+func (cs *ChangeSet) Count(kind ChangeKind) int {
+	count := 0
+	for _, c := range cs.Changes {
+		if c.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary returns a one-line description of the changes suitable for a
+// commit subject, e.g. "Add 3 bookmarks to Work/Infra, move 'Grafana' to
+// Dashboards"
+func (cs *ChangeSet) Summary() string {
+	var parts []string
+	for _, group := range []struct {
+		kind ChangeKind
+		verb string
+		prep string
+	}{
+		{Added, "add", "to"},
+		{Removed, "remove", "from"},
+		{Moved, "move", "to"},
+		{Renamed, "rename", ""},
+		{URLChanged, "update URL of", ""},
+	} {
+		for _, typ := range []string{TypeURL, TypeFolder} {
+			if part := summarize(cs.filter(group.kind, typ), group.verb, group.prep); part != "" {
+				parts = append(parts, part)
+			}
+		}
+	}
+
+	if len(parts) == 0 {
+		return "Update bookmarks"
+	}
+
+	summary := strings.Join(parts, ", ")
+	return strings.ToUpper(summary[:1]) + summary[1:]
+}
+
+// Body returns one line per change, listing names, folders and URLs
+func (cs *ChangeSet) Body() string {
+	lines := make([]string, len(cs.Changes))
+	for i, c := range cs.Changes {
+		lines[i] = c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (cs *ChangeSet) filter(kind ChangeKind, typ string) []Change {
+	var changes []Change
+	for _, c := range cs.Changes {
+		if c.Kind == kind && c.Type == typ {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// summarize describes a group of changes of the same kind and node type,
+// naming the node if there is only one
+func summarize(changes []Change, verb, prep string) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	noun := "bookmarks"
+	if changes[0].Type == TypeFolder {
+		noun = "folders"
+	}
+
+	if len(changes) == 1 {
+		c := changes[0]
+		subject := fmt.Sprintf("'%s'", c.Name)
+		if c.Type == TypeFolder {
+			subject = "folder " + subject
+		}
+		switch {
+		case c.Kind == Renamed:
+			return fmt.Sprintf("%s '%s' to '%s'", verb, c.OldName, c.Name)
+		case prep != "":
+			return fmt.Sprintf("%s %s %s %s", verb, subject, prep, shortFolder(c.Folder))
+		default:
+			return fmt.Sprintf("%s %s", verb, subject)
+		}
+	}
+
+	if verb == "update URL of" {
+		return fmt.Sprintf("update %d URLs", len(changes))
+	}
+
+	summary := fmt.Sprintf("%s %d %s", verb, len(changes), noun)
+	if prep != "" && sameFolder(changes) {
+		summary += fmt.Sprintf(" %s %s", prep, shortFolder(changes[0].Folder))
+	}
+	return summary
+}
+
+func sameFolder(changes []Change) bool {
+	for _, c := range changes[1:] {
+		if c.Folder != changes[0].Folder {
+			return false
+		}
+	}
+	return true
+}
+
+// shortFolder drops the root folder name from a path unless the path is
+// the root itself, e.g. "Bookmarks bar/Work/Infra" becomes "Work/Infra"
+func shortFolder(path string) string {
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
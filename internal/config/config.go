@@ -1,31 +1,177 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
 	"gopkg.in/yaml.v3"
 )
 
+// AutoBrowser selects the first supported browser with bookmarks
+const AutoBrowser = "auto"
+
+// AllProfiles can be listed in the profiles setting to sync every profile
+const AllProfiles = "all"
+
+// DefaultVolatileFields change on their own whenever Chrome syncs or a
+// bookmark is opened
+var DefaultVolatileFields = []string{"checksum", "sync_metadata", "date_last_used"}
+
+// Pull strategies for local commits when the remote branch has new commits
+const (
+	PullRebase = "rebase"
+	PullMerge  = "merge"
+)
+
+// DefaultCommitMessage describes the bookmark changes in the subject and
+// lists them in the body
+const DefaultCommitMessage = "{{.Summary}}\n\n{{.Body}}"
+
+// EncryptedCommitMessage is the default commit message with encryption,
+// which doesn't describe the changes
+const EncryptedCommitMessage = "Update bookmarks"
+
 type Config struct {
 	GitHubRepo     string `yaml:"github_repo"`      // e.g., "username/bookmarks"
 	GitHubToken    string `yaml:"github_token"`     // Personal access token
+	TokenCommand   string `yaml:"token_command"`    // Command printing the token, e.g. "gh auth token"
+	TokenFile      string `yaml:"token_file"`       // File containing the token, readable only by its owner
 	GitHubBranch   string `yaml:"github_branch"`    // Branch to push to (default: main)
+	PullStrategy   string `yaml:"pull_strategy"`    // How local commits are combined with remote ones: rebase or merge (default: rebase)
+	TwoWay         bool   `yaml:"two_way"`          // Apply changes from other machines to the local bookmarks
+	PullIntervalSec int   `yaml:"pull_interval_sec"` // Two-way sync: seconds between pulls (default: 300)
+	RemoteURL      string `yaml:"remote_url"`       // Any git remote URL, overrides github_repo (optional)
 	DebounceMs     int    `yaml:"debounce_ms"`      // Debounce delay in milliseconds (default: 500)
+	MinCommitInterval Duration `yaml:"min_commit_interval"` // Shortest time between commits, e.g. "30s" (default: 0, commit after debounce_ms)
+	MaxPushInterval   Duration `yaml:"max_push_interval"`   // Longest a commit waits to be pushed, pushes are at least this far apart (default: 0, push every commit)
+	DataDir        string `yaml:"data_dir"`         // Directory for the local repository and logs (default: ~/.bookmarked)
 	LogPath        string `yaml:"log_path"`         // Log file path (optional)
+	CredentialHelper bool `yaml:"credential_helper"` // Ask git's credential helpers for the token
 	CommitMessage  string `yaml:"commit_message"`   // Custom commit message template
+	Auth           AuthConfig `yaml:"auth"`          // SSH authentication (optional)
+	Browser        StringList `yaml:"browser"`       // Browsers to sync: chrome, chromium, brave, edge, vivaldi, opera, firefox or auto
+	Profiles       []string   `yaml:"profiles"`      // Profiles to sync by name or directory, or "all"
+	PathTemplate   string     `yaml:"path_template"` // Where each profile's Bookmarks.json goes, e.g. "{{.Hostname}}/{{.Browser}}/{{.Profile}}/Bookmarks.json"
+	Outputs        StringList `yaml:"outputs"`       // Formats written to the repository besides Bookmarks.json, e.g. html, markdown, csv
+	Canonical      CanonicalConfig `yaml:"canonical"` // Fields left out of the repository to avoid noise commits
+	Filters        FilterConfig    `yaml:"filters"`   // Bookmarks to leave out of the repository
+	Redaction      RedactionConfig  `yaml:"redaction"`  // Secrets removed from URLs before they are written
+	Encryption     EncryptionConfig `yaml:"encryption"` // Encrypt synced files before they are pushed (optional)
+
+	tokenResolved bool // GitHubToken was resolved by Token
 }
 
-// GetConfigPath returns the path to the config file
-func GetConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+// EncryptionConfig holds the secret synced files are encrypted with. The
+// same passphrase or key file is needed on every machine.
+type EncryptionConfig struct {
+	Passphrase string `yaml:"passphrase"` // Passphrase to derive the key from
+	KeyFile    string `yaml:"key_file"`   // File whose contents the key is derived from
+}
+
+// Enabled reports whether encryption is configured
+func (e *EncryptionConfig) Enabled() bool {
+	return e.Passphrase != "" || e.KeyFile != ""
+}
+
+// Secret returns the passphrase or the contents of the key file
+func (e *EncryptionConfig) Secret() ([]byte, error) {
+	if e.KeyFile == "" {
+		return []byte(e.Passphrase), nil
+	}
+
+	data, err := os.ReadFile(e.KeyFile)
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return nil, fmt.Errorf("failed to read encryption key file: %w", err)
 	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("encryption key file is empty: %s", e.KeyFile)
+	}
+	return data, nil
+}
 
-	configDir := filepath.Join(homeDir, ".bookmarked")
-	return filepath.Join(configDir, "config.yaml"), nil
+// RedactionConfig controls how secrets such as access tokens and signed URL
+// parameters are replaced before bookmarks are written to the repository
+type RedactionConfig struct {
+	DisableBuiltin bool     `yaml:"disable_builtin"` // Skip the built-in rules for tokens, JWTs and AWS signatures
+	Placeholder    string   `yaml:"placeholder"`     // Replaces secrets (default: REDACTED)
+	Params         []string `yaml:"params"`          // Additional query parameter names to redact
+	Patterns       []string `yaml:"patterns"`        // Regular expressions redacted in URLs and titles
+}
+
+// FilterConfig selects the bookmarks that are synced. Excluded bookmarks
+// never leave the machine; with include rules only matching ones are synced.
+type FilterConfig struct {
+	Include []FilterRule `yaml:"include"`
+	Exclude []FilterRule `yaml:"exclude"`
+}
+
+// FilterRule matches bookmarks on all of the conditions that are set
+type FilterRule struct {
+	Folder string `yaml:"folder"` // Folder path glob, "**" matches any depth, e.g. "Bookmarks bar/Private/**"
+	URL    string `yaml:"url"`    // Regular expression matched against the URL
+	Domain string `yaml:"domain"` // Host wildcard, e.g. "*.internal.corp"
+	Title  string `yaml:"title"`  // Title wildcard, e.g. "*payslip*"
+}
+
+// CanonicalConfig lists the fields Chrome rewrites without a meaningful
+// change, which are left out of Bookmarks.json
+type CanonicalConfig struct {
+	VolatileFields []string `yaml:"volatile_fields"` // Default: checksum, sync_metadata, date_last_used
+	Separate       bool     `yaml:"separate"`        // Keep their values in Bookmarks.volatile.json, updated only along with other changes
+}
+
+// Duration is a time.Duration written like "30s" or "5m" in YAML
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, use e.g. \"30s\" or \"5m\"", value.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// StringList is a list of strings that may also be written as a single
+// string in YAML
+type StringList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// AuthConfig holds SSH authentication settings for ssh:// and scp-style remotes
+type AuthConfig struct {
+	SSHKey                string `yaml:"ssh_key"`                  // Path to a private key file
+	SSHKeyPassphrase      string `yaml:"ssh_key_passphrase"`       // Passphrase for the private key (optional)
+	SSHAgent              bool   `yaml:"ssh_agent"`                // Use keys from the running ssh-agent
+	SSHUser               string `yaml:"ssh_user"`                 // SSH user if not in the URL (default: git)
+	KnownHosts            string `yaml:"known_hosts"`              // known_hosts file (default: ~/.ssh/known_hosts)
+	InsecureIgnoreHostKey bool   `yaml:"insecure_ignore_host_key"` // Skip host key verification (not recommended)
+}
+
+// UsesSSH reports whether any SSH authentication option is set
+func (a *AuthConfig) UsesSSH() bool {
+	return a.SSHKey != "" || a.SSHAgent
 }
 
 // Load reads and parses the config file
@@ -49,24 +195,134 @@ func Load() (*Config, error) {
 	if cfg.GitHubBranch == "" {
 		cfg.GitHubBranch = "main"
 	}
+	if len(cfg.Browser) == 0 {
+		cfg.Browser = StringList{"chrome"}
+	}
+	if cfg.PullStrategy == "" {
+		cfg.PullStrategy = PullRebase
+	}
+	if cfg.PullIntervalSec == 0 {
+		cfg.PullIntervalSec = 300
+	}
 	if cfg.DebounceMs == 0 {
 		cfg.DebounceMs = 500
 	}
 	if cfg.CommitMessage == "" {
-		cfg.CommitMessage = "Update bookmarks"
+		cfg.CommitMessage = DefaultCommitMessage
+		if cfg.Encryption.Enabled() {
+			// The default message would reveal bookmark names and URLs
+			cfg.CommitMessage = EncryptedCommitMessage
+		}
+	}
+	if cfg.Canonical.VolatileFields == nil {
+		cfg.Canonical.VolatileFields = DefaultVolatileFields
 	}
 
 	// Validate required fields
-	if cfg.GitHubRepo == "" {
-		return nil, fmt.Errorf("github_repo is required in config file")
+	if cfg.GitHubRepo == "" && cfg.RemoteURL == "" {
+		return nil, fmt.Errorf("github_repo or remote_url is required in config file")
+	}
+	if cfg.TokenFile, err = ExpandPath(cfg.TokenFile); err != nil {
+		return nil, err
+	}
+	if cfg.IsHTTPRemote() && !cfg.hasTokenSource() {
+		return nil, fmt.Errorf("a token is required for HTTPS remotes, set one of: %s", tokenOptions)
+	}
+	if cfg.Auth.UsesSSH() && cfg.IsHTTPRemote() {
+		return nil, fmt.Errorf("auth.ssh_key and auth.ssh_agent require an SSH remote_url (e.g. git@github.com:user/repo.git)")
+	}
+	if cfg.Auth.SSHKey != "" && cfg.Auth.SSHAgent {
+		return nil, fmt.Errorf("auth.ssh_key and auth.ssh_agent cannot both be set")
+	}
+	if cfg.MinCommitInterval < 0 || cfg.MaxPushInterval < 0 {
+		return nil, fmt.Errorf("min_commit_interval and max_push_interval must be positive")
+	}
+	for _, id := range cfg.Browser {
+		if strings.EqualFold(id, AutoBrowser) {
+			continue
+		}
+		if _, err := bookmarks.FindBrowser(id); err != nil {
+			return nil, fmt.Errorf("invalid browser setting: %w", err)
+		}
 	}
-	if cfg.GitHubToken == "" {
-		return nil, fmt.Errorf("github_token is required in config file")
+	if cfg.PullIntervalSec < 0 {
+		return nil, fmt.Errorf("pull_interval_sec must be positive")
+	}
+	if cfg.PullStrategy != PullRebase && cfg.PullStrategy != PullMerge {
+		return nil, fmt.Errorf("invalid pull_strategy %q, must be %q or %q", cfg.PullStrategy, PullRebase, PullMerge)
+	}
+	if cfg.Encryption.Passphrase != "" && cfg.Encryption.KeyFile != "" {
+		return nil, fmt.Errorf("encryption.passphrase and encryption.key_file cannot both be set")
+	}
+
+	if _, err := template.New("commit_message").Parse(cfg.CommitMessage); err != nil {
+		return nil, fmt.Errorf("invalid commit_message template: %w", err)
+	}
+	if cfg.PathTemplate != "" {
+		if _, err := template.New("path_template").Parse(cfg.PathTemplate); err != nil {
+			return nil, fmt.Errorf("invalid path_template: %w", err)
+		}
+		if path.Base(filepath.ToSlash(cfg.PathTemplate)) != "Bookmarks.json" {
+			return nil, fmt.Errorf("path_template must end in /Bookmarks.json")
+		}
+	}
+
+	// Expand ~ in file paths
+	if cfg.Auth.SSHKey, err = ExpandPath(cfg.Auth.SSHKey); err != nil {
+		return nil, err
+	}
+	if cfg.Auth.KnownHosts, err = ExpandPath(cfg.Auth.KnownHosts); err != nil {
+		return nil, err
+	}
+	if cfg.Encryption.KeyFile, err = ExpandPath(cfg.Encryption.KeyFile); err != nil {
+		return nil, err
+	}
+	if cfg.LogPath, err = ExpandPath(cfg.LogPath); err != nil {
+		return nil, err
+	}
+	if cfg.DataDir == "" {
+		if cfg.DataDir, err = DefaultDataDir(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.DataDir, err = ExpandPath(cfg.DataDir); err != nil {
+		return nil, err
+	}
+	if cfg.DataDir, err = filepath.Abs(cfg.DataDir); err != nil {
+		return nil, fmt.Errorf("failed to resolve data_dir: %w", err)
 	}
 
 	return &cfg, nil
 }
 
+// ExpandPath replaces a leading ~ in path with the user's home directory
+func ExpandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
+
+// RepoURL returns the URL of the git remote to sync with
+func (c *Config) RepoURL() string {
+	if c.RemoteURL != "" {
+		return c.RemoteURL
+	}
+	return fmt.Sprintf("https://github.com/%s.git", c.GitHubRepo)
+}
+
+// IsHTTPRemote reports whether the remote is reached over HTTP(S), which
+// requires a token for authentication
+func (c *Config) IsHTTPRemote() bool {
+	url := strings.ToLower(c.RepoURL())
+	return strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://")
+}
+
 // InitConfig creates a new config file with template
 func InitConfig() error {
 	configPath, err := GetConfigPath()
@@ -92,19 +348,121 @@ github_repo: ""
 
 # GitHub personal access token with repo write permissions
 # Create one at: https://github.com/settings/tokens
+# To keep it out of this file, leave it empty and use one of:
+#   the BOOKMARKED_GITHUB_TOKEN environment variable
+#   token_command: "gh auth token"      (or "pass show github", "op read ...")
+#   token_file: "~/.bookmarked/token"   (must not be readable by others)
+#   credential_helper: true             (git credential helpers, e.g. the OS keychain)
 github_token: ""
 
 # Branch to push to (optional, default: main)
 github_branch: "main"
 
+# When another machine pushed to the branch, rebase the local commits onto
+# its commits or merge them (optional, default: rebase)
+pull_strategy: "rebase"
+
+# Also apply bookmark changes from other machines to this browser, while it
+# is closed (optional, default: false)
+two_way: false
+# Seconds between pulls of remote changes with two_way (default: 300)
+pull_interval_sec: 300
+
+# Any git remote to use instead of GitHub (optional), e.g.
+# "https://gitea.example.com/me/bookmarks.git", "git@gitlab.example.com:me/bookmarks.git"
+# or "file:///mnt/nas/bookmarks.git". github_token is only needed for HTTPS remotes.
+remote_url: ""
+
+# Browser to sync (optional, default: chrome). One of chrome, chromium, brave,
+# edge, vivaldi, opera, firefox, or auto for the first one found. List several
+# to sync them side by side, e.g. [chrome, firefox]. Run 'bookmarked browsers'
+# to see which are installed.
+browser: "chrome"
+
+# Profiles to sync (optional, default: only the Default profile)
+# List display names or directory names, or "all". Each profile is stored
+# under profiles/<name>/ in the repository. Run 'bookmarked profiles' to list them.
+# profiles: ["Work", "Personal"]
+
+# Where each profile's Bookmarks.json is stored in the repository (optional).
+# A Go template with .Hostname, .Browser and .Profile that must end in
+# Bookmarks.json. Set it to share one repository between several machines,
+# each in its own directory; 'bookmarked machines' lists them.
+# path_template: "{{.Hostname}}/{{.Browser}}/{{.Profile}}/Bookmarks.json"
+
+# Additional formats written to the repository on each sync (optional).
+# Bookmarks.json is always written. "html" adds a bookmarks.html that any
+# browser can import, "markdown" a BOOKMARKS.md and "markdown-tree" one
+# markdown file per folder under bookmarks/. "csv" and "jsonl" write one
+# row per bookmark for spreadsheets and data tools.
+# outputs: ["html", "markdown"]
+
+# Fields Chrome changes without a meaningful edit are left out of
+# Bookmarks.json so they don't cause commits (optional). Set separate to keep
+# their values in Bookmarks.volatile.json, updated only with other changes.
+# canonical:
+#   volatile_fields: ["checksum", "sync_metadata", "date_last_used"]
+#   separate: false
+
+# Bookmarks to keep off the repository (optional). A rule matches when all
+# of its conditions do: folder (glob, ** for any depth), url (regex), domain
+# and title (wildcards). Run 'bookmarked sync --dry-run --explain' to check.
+# filters:
+#   exclude:
+#     - folder: "Bookmarks bar/Private/**"
+#     - domain: "*.internal.corp"
+
+# Secrets in URLs are replaced with REDACTED before anything is written:
+# token, password and signature query parameters, JWTs and AWS keys. Add
+# your own parameter names and regular expressions (optional). Run
+# 'bookmarked scan' to look for secrets in the repository history.
+# redaction:
+#   params: ["ticket"]
+#   patterns: ["sso-[0-9a-f]{32}"]
+
+# Encrypt the synced files with AES-256-GCM before they are pushed (optional).
+# The key is derived from a passphrase or the contents of a key file with
+# scrypt; use the same one on every machine. Keep a copy, without it the
+# synced bookmarks can't be read. Commit messages then default to "Update
+# bookmarks" rather than listing the changes.
+# encryption:
+#   passphrase: ""
+#   key_file: "~/.bookmarked/key"
+
+# SSH authentication for ssh:// and scp-style remotes (optional)
+# Without ssh_key, keys are taken from the running ssh-agent.
+auth:
+  ssh_key: ""              # e.g. "~/.ssh/id_ed25519" (a deploy key works)
+  ssh_key_passphrase: ""
+  ssh_agent: false
+  known_hosts: ""          # default: ~/.ssh/known_hosts
+  # ssh_user: "git"                  # when the URL doesn't include one
+  # insecure_ignore_host_key: false  # skip host key verification (not recommended)
+
 # Debounce delay in milliseconds (optional, default: 500)
 debounce_ms: 500
 
+# Rate-limit commits and pushes, so that bulk operations such as importing
+# or reorganizing many bookmarks don't make a commit and a push per change
+# (optional). The first change is committed right away, later ones at least
+# min_commit_interval after the previous commit, together with the changes
+# made in between. Pushes are at least max_push_interval apart, so no commit
+# waits longer than that to be pushed.
+# min_commit_interval: "30s"   # default: 0, commit after debounce_ms
+# max_push_interval: "10m"     # default: 0, push every commit
+
+# Directory for the local repository clone and the service log
+# (optional, default: ~/.bookmarked). Give each config its own data_dir to
+# run several instances side by side.
+data_dir: ""
+
 # Log file path (optional, logs to stdout if not set)
 log_path: ""
 
-# Commit message template (optional, default: "Update bookmarks")
-commit_message: "Update bookmarks"
+# Commit message Go template (optional). Available fields: .Summary, .Body,
+# .Changes, .Hostname, .Profile, .Added, .Removed, .Renamed, .Moved,
+# .URLChanged and .Time
+commit_message: "{{.Summary}}\n\n{{.Body}}"
 `
 
 	if err := os.WriteFile(configPath, []byte(template), 0600); err != nil {
@@ -119,14 +477,3 @@ commit_message: "Update bookmarks"
 
 	return nil
 }
-
-// GetRepoPath returns the local repository path
-func GetRepoPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	repoPath := filepath.Join(homeDir, ".bookmarked", "repo")
-	return repoPath, nil
-}
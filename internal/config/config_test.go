@@ -0,0 +1,37 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadConfig loads a config file with the given contents
+func loadConfig(t *testing.T, contents string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	SetConfigPath(path)
+	t.Cleanup(func() { SetConfigPath("") })
+	return Load()
+}
+
+func TestLoadValidatesBrowsers(t *testing.T) {
+	tests := []struct {
+		browser string
+		valid   bool
+	}{
+		{`"chrome"`, true},
+		{`["Chrome", "FIREFOX"]`, true},
+		{`"Auto"`, true},
+		{`["chrome", "netscape"]`, false},
+	}
+	for _, tt := range tests {
+		_, err := loadConfig(t, "remote_url: \"file:///tmp/bookmarks.git\"\nbrowser: "+tt.browser+"\n")
+		if (err == nil) != tt.valid {
+			t.Errorf("browser: %s: got error %v, want valid %v", tt.browser, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ConfigEnvVar names the environment variable that selects the config file
+const ConfigEnvVar = "BOOKMARKED_CONFIG"
+
+// configPath is set by the --config flag and takes precedence over
+// ConfigEnvVar
+var configPath string
+
+// SetConfigPath makes GetConfigPath return path, e.g. from a command line flag
+func SetConfigPath(path string) {
+	configPath = path
+}
+
+// GetConfigPath returns the path to the config file: the --config flag,
+// BOOKMARKED_CONFIG, or ~/.bookmarked/config.yaml. On Linux
+// $XDG_CONFIG_HOME/bookmarked/config.yaml is used instead when
+// XDG_CONFIG_HOME is set and ~/.bookmarked/config.yaml doesn't exist.
+func GetConfigPath() (string, error) {
+	path := configPath
+	if path == "" {
+		path = os.Getenv(ConfigEnvVar)
+	}
+	if path == "" {
+		return DefaultConfigPath()
+	}
+
+	path, err := ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	return absPath, nil
+}
+
+// DefaultConfigPath returns the config file used without the --config flag
+// and BOOKMARKED_CONFIG
+func DefaultConfigPath() (string, error) {
+	dataDir, err := DefaultDataDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dataDir, "config.yaml")
+
+	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	if runtime.GOOS != "linux" || !filepath.IsAbs(xdgConfigHome) {
+		return path, nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		// Keep using an existing config from before XDG was supported
+		return path, nil
+	}
+	return filepath.Join(xdgConfigHome, "bookmarked", "config.yaml"), nil
+}
+
+// DefaultDataDir returns the data directory used when data_dir isn't set
+func DefaultDataDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".bookmarked"), nil
+}
+
+// RepoPath returns the local repository path inside the data directory
+func (c *Config) RepoPath() string {
+	return filepath.Join(c.DataDir, "repo")
+}
+
+// ServiceLogPath returns the file the background service's output goes to
+func (c *Config) ServiceLogPath() string {
+	return filepath.Join(c.DataDir, "bookmarked.log")
+}
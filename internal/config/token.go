@@ -0,0 +1,143 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// TokenEnvVar overrides the token in the config file
+const TokenEnvVar = "BOOKMARKED_GITHUB_TOKEN"
+
+// tokenOptions lists the ways to provide a token, for error messages
+const tokenOptions = "github_token, the " + TokenEnvVar + " environment variable, token_command, token_file, or credential_helper: true"
+
+// hasTokenSource reports whether a way to get the token is configured,
+// without running token_command or the credential helpers
+func (c *Config) hasTokenSource() bool {
+	return strings.TrimSpace(os.Getenv(TokenEnvVar)) != "" || c.GitHubToken != "" ||
+		c.TokenCommand != "" || c.TokenFile != "" || c.CredentialHelper
+}
+
+// Token returns the token for HTTPS remotes. It is resolved when first
+// needed, so that commands that don't reach the remote don't run
+// token_command or ask the credential helpers.
+func (c *Config) Token() (string, error) {
+	if c.tokenResolved {
+		return c.GitHubToken, nil
+	}
+	if err := c.resolveToken(); err != nil {
+		return "", err
+	}
+	if c.GitHubToken == "" {
+		return "", fmt.Errorf("a token is required for HTTPS remotes, set one of: %s", tokenOptions)
+	}
+	c.tokenResolved = true
+	return c.GitHubToken, nil
+}
+
+// resolveToken fills in GitHubToken from the first configured source: the
+// environment, the config file itself, token_command, token_file, then the
+// git credential helpers
+func (c *Config) resolveToken() error {
+	if token := strings.TrimSpace(os.Getenv(TokenEnvVar)); token != "" {
+		c.GitHubToken = token
+		return nil
+	}
+	if c.GitHubToken != "" {
+		return nil
+	}
+
+	var err error
+	switch {
+	case c.TokenCommand != "":
+		c.GitHubToken, err = runTokenCommand(c.TokenCommand)
+	case c.TokenFile != "":
+		c.GitHubToken, err = readTokenFile(c.TokenFile)
+	case c.CredentialHelper:
+		c.GitHubToken, err = gitCredential(c.RepoURL())
+	}
+	return err
+}
+
+// runTokenCommand runs a shell command that prints the token, e.g.
+// "gh auth token" or "pass show github/bookmarked"
+func runTokenCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("token_command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// Password managers may print more lines, the token is the first
+	token, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("token_command printed no token")
+	}
+	return token, nil
+}
+
+// readTokenFile reads a token from a file that only its owner can read
+func readTokenFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token_file: %w", err)
+	}
+	// Windows has no permission bits to check
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("token_file %s is accessible by other users (mode %04o), run: chmod 600 %s", path, info.Mode().Perm(), path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token_file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token_file %s is empty", path)
+	}
+	return token, nil
+}
+
+// gitCredential asks git's credential helpers (e.g. the OS keychain or the
+// GitHub CLI) for the password of the remote, without prompting
+func gitCredential(repoURL string) (string, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse remote URL: %w", err)
+	}
+
+	input := fmt.Sprintf("protocol=%s\nhost=%s\n", u.Scheme, u.Host)
+	if u.User != nil && u.User.Username() != "" {
+		input += fmt.Sprintf("username=%s\n", u.User.Username())
+	}
+	input += "\n"
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git credential helper has no token for %s: %w", u.Host, err)
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if password, ok := strings.CutPrefix(line, "password="); ok && password != "" {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("git credential helper has no token for %s", u.Host)
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestTokenIsResolvedOnFirstUse(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("token_command is a shell command")
+	}
+	t.Setenv(TokenEnvVar, "")
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "runs")
+	cfg := &Config{
+		RemoteURL:    "https://example.com/bookmarks.git",
+		TokenCommand: "echo run >> '" + counter + "'; echo secret",
+	}
+
+	if !cfg.hasTokenSource() {
+		t.Fatal("token_command isn't a token source")
+	}
+	if _, err := os.Stat(counter); !os.IsNotExist(err) {
+		t.Fatal("token_command ran before the token was needed")
+	}
+
+	for i := 0; i < 2; i++ {
+		token, err := cfg.Token()
+		if err != nil || token != "secret" {
+			t.Fatalf("Token = %q, %v", token, err)
+		}
+	}
+	runs, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(runs) != "run\n" {
+		t.Errorf("token_command ran %q, want once", runs)
+	}
+}
+
+func TestTokenWithoutSource(t *testing.T) {
+	t.Setenv(TokenEnvVar, "")
+	cfg := &Config{RemoteURL: "https://example.com/bookmarks.git"}
+	if cfg.hasTokenSource() {
+		t.Error("hasTokenSource without any source")
+	}
+	if _, err := cfg.Token(); err == nil {
+		t.Error("Token succeeded without a source")
+	}
+}
@@ -0,0 +1,159 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ParamsFileName is the file in the repository root holding the key
+// derivation parameters. It contains no secrets.
+const ParamsFileName = "encryption.json"
+
+// magic starts every encrypted file
+const magic = "BKMKENC1"
+
+// checkMessage is authenticated with the derived key to detect a wrong
+// passphrase before decrypting anything
+const checkMessage = "bookmarked key check"
+
+// ErrWrongKey is returned when the passphrase or key file doesn't match the
+// one the repository was encrypted with
+var ErrWrongKey = errors.New("wrong passphrase or key file for this repository")
+
+// Params are the scrypt parameters and salt shared by all encrypted files
+// of a repository
+type Params struct {
+	Version int    `json:"version"`
+	LogN    int    `json:"log_n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Check   []byte `json:"check,omitempty"` // HMAC of checkMessage with the derived key
+}
+
+// Key encrypts and decrypts files
+type Key struct {
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+// NewParams returns parameters with a random salt
+func NewParams() (*Params, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return &Params{Version: 1, LogN: 15, R: 8, P: 1, Salt: salt}, nil
+}
+
+// LoadParams reads the parameters from the repository root, returning nil
+// if the repository isn't encrypted yet
+func LoadParams(repoPath string) (*Params, error) {
+	data, err := os.ReadFile(filepath.Join(repoPath, ParamsFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption parameters: %w", err)
+	}
+
+	var p Params
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ParamsFileName, err)
+	}
+	if p.Version != 1 {
+		return nil, fmt.Errorf("unsupported encryption version %d", p.Version)
+	}
+	return &p, nil
+}
+
+// Save writes the parameters to the repository root
+func (p *Params) Save(repoPath string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode encryption parameters: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, ParamsFileName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write encryption parameters: %w", err)
+	}
+	return nil
+}
+
+// DeriveKey derives the key from a passphrase or key file contents with
+// scrypt. If the parameters have no check value yet it is set, otherwise
+// it is verified.
+func DeriveKey(secret []byte, p *Params) (*Key, error) {
+	derived, err := scrypt.Key(secret, p.Salt, 1<<p.LogN, p.R, p.P, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	k := &Key{aead: aead, macKey: derived[32:]}
+
+	check := k.mac([]byte(checkMessage))
+	if p.Check == nil {
+		p.Check = check
+	} else if !hmac.Equal(p.Check, check) {
+		return nil, ErrWrongKey
+	}
+	return k, nil
+}
+
+// Encrypt seals data with AES-GCM. The nonce is derived from the contents,
+// so unchanged files encrypt to the same bytes and don't show up as changes.
+func (k *Key) Encrypt(data []byte) []byte {
+	nonce := k.mac(data)[:k.aead.NonceSize()]
+
+	out := make([]byte, 0, len(magic)+len(nonce)+len(data)+k.aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	return k.aead.Seal(out, nonce, data, []byte(magic))
+}
+
+// Decrypt opens data sealed by Encrypt
+func (k *Key) Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, errors.New("not an encrypted file")
+	}
+	data = data[len(magic):]
+	if len(data) < k.aead.NonceSize() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+
+	nonce, sealed := data[:k.aead.NonceSize()], data[k.aead.NonceSize():]
+	plain, err := k.aead.Open(nil, nonce, sealed, []byte(magic))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plain, nil
+}
+
+// IsEncrypted reports whether data was written by Encrypt
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+func (k *Key) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, k.macKey)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"log"
+	stdsync "sync"
+	"time"
+)
+
+// batcher rate-limits the commits and pushes of the service, so that bulk
+// operations don't produce a commit and a push per change. Commits are at
+// least minCommit apart, changes made in between are committed together.
+// Pushes are at least minPush apart, commits made in between are kept
+// locally and pushed together.
+type batcher struct {
+	minCommit time.Duration
+	minPush   time.Duration
+	commit    func() bool // Commits the changes, reports whether there was a commit
+	push      func()
+
+	mu          stdsync.Mutex
+	commitTimer *time.Timer // Pending commit, nil if there is none
+	pushTimer   *time.Timer // Pending push, nil if there is none
+	lastCommit  time.Time
+	lastPush    time.Time
+	stopped     bool
+}
+
+func newBatcher(minCommit, minPush time.Duration, commit func() bool, push func()) *batcher {
+	return &batcher{minCommit: minCommit, minPush: minPush, commit: commit, push: push}
+}
+
+// changed schedules a commit of the changes, right away unless the last
+// commit was less than minCommit ago
+func (b *batcher) changed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped || b.commitTimer != nil {
+		return // the pending commit includes this change
+	}
+	delay := time.Until(b.lastCommit.Add(b.minCommit))
+	if delay > time.Second {
+		log.Printf("Committing the changes in %s", delay.Round(time.Second))
+	}
+	b.commitTimer = time.AfterFunc(delay, b.fireCommit)
+}
+
+func (b *batcher) fireCommit() {
+	b.mu.Lock()
+	if b.stopped || b.commitTimer == nil {
+		b.mu.Unlock()
+		return
+	}
+	b.commitTimer = nil
+	b.lastCommit = time.Now()
+	b.mu.Unlock()
+
+	if !b.commit() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped || b.pushTimer != nil {
+		return // the pending push includes this commit
+	}
+	delay := time.Until(b.lastPush.Add(b.minPush))
+	if delay > time.Second {
+		log.Printf("Pushing the commits in %s", delay.Round(time.Second))
+	}
+	b.pushTimer = time.AfterFunc(delay, b.firePush)
+}
+
+func (b *batcher) firePush() {
+	b.mu.Lock()
+	if b.stopped || b.pushTimer == nil {
+		b.mu.Unlock()
+		return
+	}
+	b.pushTimer = nil
+	b.lastPush = time.Now()
+	b.mu.Unlock()
+
+	b.push()
+}
+
+// stop cancels the pending commit and push and reports whether there were
+// changes left to commit
+func (b *batcher) stop() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+	if b.pushTimer != nil {
+		b.pushTimer.Stop()
+		b.pushTimer = nil
+	}
+	if b.commitTimer == nil {
+		return false
+	}
+	b.commitTimer.Stop()
+	b.commitTimer = nil
+	return true
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	stdsync "sync"
+	"testing"
+	"time"
+)
+
+type batchLog struct {
+	mu      stdsync.Mutex
+	commits []time.Time
+	pushes  []time.Time
+}
+
+func (l *batchLog) commit() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.commits = append(l.commits, time.Now())
+	return true
+}
+
+func (l *batchLog) push() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pushes = append(l.pushes, time.Now())
+}
+
+func (l *batchLog) counts() (int, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.commits), len(l.pushes)
+}
+
+func minGap(times []time.Time) time.Duration {
+	gap := time.Duration(1<<63 - 1)
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[i-1]); d < gap {
+			gap = d
+		}
+	}
+	return gap
+}
+
+func TestBatcherRateLimits(t *testing.T) {
+	const minCommit, minPush = 40 * time.Millisecond, 100 * time.Millisecond
+	var l batchLog
+	b := newBatcher(minCommit, minPush, l.commit, l.push)
+
+	// A bulk operation changing the bookmarks for 200ms
+	for start := time.Now(); time.Since(start) < 200*time.Millisecond; {
+		b.changed()
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(minPush + 50*time.Millisecond)
+
+	commits, pushes := l.counts()
+	if commits < 2 || commits > 7 {
+		t.Errorf("%d commits, want one per %s", commits, minCommit)
+	}
+	if pushes < 2 || pushes > 4 {
+		t.Errorf("%d pushes, want one per %s", pushes, minPush)
+	}
+	if gap := minGap(l.commits); gap < minCommit-5*time.Millisecond {
+		t.Errorf("commits %s apart, want at least %s", gap, minCommit)
+	}
+	if gap := minGap(l.pushes); gap < minPush-5*time.Millisecond {
+		t.Errorf("pushes %s apart, want at least %s", gap, minPush)
+	}
+	if last := l.pushes[len(l.pushes)-1]; last.Before(l.commits[len(l.commits)-1]) {
+		t.Error("the last commit wasn't pushed")
+	}
+	if b.stop() {
+		t.Error("stop reported pending changes after they were committed")
+	}
+}
+
+func TestBatcherWithoutLimits(t *testing.T) {
+	var l batchLog
+	b := newBatcher(0, 0, l.commit, l.push)
+	b.changed()
+	time.Sleep(20 * time.Millisecond)
+	if commits, pushes := l.counts(); commits != 1 || pushes != 1 {
+		t.Errorf("%d commits and %d pushes, want each change committed and pushed", commits, pushes)
+	}
+}
+
+func TestBatcherStop(t *testing.T) {
+	var l batchLog
+	b := newBatcher(time.Hour, time.Hour, l.commit, l.push)
+	b.changed()
+	time.Sleep(20 * time.Millisecond)
+
+	// The second change waits for the commit interval
+	b.changed()
+	if !b.stop() {
+		t.Error("stop didn't report the pending change")
+	}
+	b.changed()
+	time.Sleep(20 * time.Millisecond)
+	if commits, _ := l.counts(); commits != 1 {
+		t.Errorf("%d commits, want only the first", commits)
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+)
+
+// commitData is the data available to the commit_message template
+type commitData struct {
+	Summary    string             // e.g. "Add 3 bookmarks to Work/Infra, remove 'Old'"
+	Body       string             // One line per change with names, folders and URLs
+	Changes    []bookmarks.Change // The individual changes
+	Hostname   string             // Name of this machine
+	Profile    string             // Chrome profile that was synced
+	Added      int
+	Removed    int
+	Renamed    int
+	Moved      int
+	URLChanged int
+	Time       time.Time
+}
+
+// profileChanges are the changes synced from one profile
+type profileChanges struct {
+	profile string
+	changes *bookmarks.ChangeSet
+}
+
+// commitMessage renders the commit_message template for the changes of all
+// synced profiles
+func (s *Service) commitMessage(synced []profileChanges) (string, error) {
+	tmpl, err := template.New("commit_message").Parse(s.cfg.CommitMessage)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse commit_message template: %w", err)
+	}
+
+	// Combine the changes, labelling the body per profile if there are several
+	changes := &bookmarks.ChangeSet{}
+	var profiles, bodies []string
+	for _, pc := range synced {
+		if pc.changes.IsEmpty() {
+			continue
+		}
+		changes.Changes = append(changes.Changes, pc.changes.Changes...)
+		profiles = append(profiles, pc.profile)
+		if len(synced) > 1 {
+			bodies = append(bodies, pc.profile+":\n"+pc.changes.Body())
+		} else {
+			bodies = append(bodies, pc.changes.Body())
+		}
+	}
+
+	data := commitData{
+		Summary:    changes.Summary(),
+		Body:       strings.Join(bodies, "\n\n"),
+		Changes:    changes.Changes,
+		Hostname:   hostname(),
+		Profile:    strings.Join(profiles, ", "),
+		Added:      changes.Count(bookmarks.Added),
+		Removed:    changes.Count(bookmarks.Removed),
+		Renamed:    changes.Count(bookmarks.Renamed),
+		Moved:      changes.Count(bookmarks.Moved),
+		URLChanged: changes.Count(bookmarks.URLChanged),
+		Time:       time.Now(),
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render commit_message template: %w", err)
+	}
+
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+// hostname returns the name of this machine
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+	"github.com/vivek-dodia/bookmarked-cli/internal/sync"
+)
+
+// DiffOptions controls how Diff compares and prints bookmarks
+type DiffOptions struct {
+	Profile string // Synced profile to compare, required if several are synced
+	JSON    bool   // Print the changes as JSON
+}
+
+// Diff writes the bookmark changes between two versions of the synced file.
+// With no revisions HEAD is compared to the live Chrome file, with one the
+// given revision is compared to the live file, and with two the revisions
+// are compared to each other.
+func (s *Service) Diff(revs []string, opts DiffOptions, w io.Writer) error {
+	if len(revs) > 2 {
+		return fmt.Errorf("expected at most 2 revisions, got %d", len(revs))
+	}
+
+	if err := s.setup(); err != nil {
+		return err
+	}
+
+	src, err := s.findSource(opts.Profile)
+	if err != nil {
+		return err
+	}
+
+	oldRev := "HEAD"
+	if len(revs) > 0 {
+		oldRev = revs[0]
+	}
+	oldFile, err := s.loadRevision(oldRev, src.repoFile())
+	if err != nil {
+		return err
+	}
+
+	var newFile *bookmarks.File
+	if len(revs) == 2 {
+		newFile, err = s.loadRevision(revs[1], src.repoFile())
+	} else {
+		newFile, _, err = s.loadLive(src)
+	}
+	if err != nil {
+		return err
+	}
+
+	changes := bookmarks.Diff(oldFile, newFile)
+	if opts.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(changes)
+	}
+	return changes.WriteText(w)
+}
+
+// loadRevision reads a synced bookmarks file at a revision of the repository.
+// A revision without the file yields nil, an empty tree.
+func (s *Service) loadRevision(rev, path string) (*bookmarks.File, error) {
+	data, err := s.gitSync.ReadFile(rev, path)
+	if errors.Is(err, sync.ErrFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data, err = s.decrypt(data); err != nil {
+		return nil, err
+	}
+	return bookmarks.Parse(data)
+}
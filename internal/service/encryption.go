@@ -0,0 +1,90 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+	"github.com/vivek-dodia/bookmarked-cli/internal/crypt"
+)
+
+// setupEncryption derives the key for the repository if encryption is
+// configured and the repository has key parameters. Without them the key
+// is only created by newEncryptionKey, so that reading commands don't write
+// to the repository.
+func (s *Service) setupEncryption() error {
+	if !s.cfg.Encryption.Enabled() {
+		return nil
+	}
+
+	params, err := crypt.LoadParams(s.gitSync.GetRepoPath())
+	if err != nil || params == nil {
+		return err
+	}
+	return s.deriveKey(params)
+}
+
+// newEncryptionKey gives a repository encrypted for the first time new key
+// parameters, committed along with the encrypted files. Only syncs that
+// commit call it.
+func (s *Service) newEncryptionKey() error {
+	if !s.cfg.Encryption.Enabled() || s.key != nil {
+		return nil
+	}
+
+	repoPath := s.gitSync.GetRepoPath()
+	params, err := crypt.LoadParams(repoPath)
+	if err != nil {
+		return err
+	}
+	if params != nil {
+		// Pulled from another machine since the repository was opened
+		return s.deriveKey(params)
+	}
+
+	if params, err = crypt.NewParams(); err != nil {
+		return err
+	}
+	if err := s.deriveKey(params); err != nil {
+		return err
+	}
+	log.Println("Encrypting the repository with a new key")
+	return params.Save(repoPath)
+}
+
+func (s *Service) deriveKey(params *crypt.Params) error {
+	secret, err := s.cfg.Encryption.Secret()
+	if err != nil {
+		return err
+	}
+	s.key, err = crypt.DeriveKey(secret, params)
+	return err
+}
+
+// repoWriter returns the writer for synced files, encrypting them if
+// encryption is configured
+func (s *Service) repoWriter() *bookmarks.RepoWriter {
+	return &bookmarks.RepoWriter{
+		Outputs:   s.cfg.Outputs,
+		WriteFile: s.writeRepoFile,
+	}
+}
+
+func (s *Service) writeRepoFile(path string, data []byte) error {
+	if s.key != nil {
+		data = s.key.Encrypt(data)
+	}
+	return bookmarks.WritePlainFile(path, data)
+}
+
+// decrypt returns the plain contents of a file from the repository, which
+// may or may not be encrypted
+func (s *Service) decrypt(data []byte) ([]byte, error) {
+	if !crypt.IsEncrypted(data) {
+		return data, nil
+	}
+	if s.key == nil {
+		return nil, fmt.Errorf("the synced bookmarks are encrypted, set encryption.passphrase or encryption.key_file in the config file")
+	}
+	return s.key.Decrypt(data)
+}
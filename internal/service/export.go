@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+)
+
+// ExportOptions controls what Export writes
+type ExportOptions struct {
+	Format  string // Output format, e.g. "html"
+	Output  string // File (or directory for tree formats) to write, stdout if empty
+	Profile string // Synced profile to export, required if several are synced
+	Rev     string // Export a synced revision instead of the live bookmarks
+}
+
+// Export writes a profile's bookmarks in another format, either the live
+// browser bookmarks or the version synced at a revision
+func (s *Service) Export(opts ExportOptions, stdout io.Writer) error {
+	exporter, err := bookmarks.FindExporter(opts.Format)
+	if err != nil {
+		return err
+	}
+	if exporter.IsDir() && opts.Output == "" {
+		return fmt.Errorf("the %s format writes a directory, choose one with --output", exporter.Format)
+	}
+
+	// The repository is only needed to read an older revision
+	if opts.Rev != "" {
+		err = s.setup()
+	} else {
+		s.sources, err = resolveSources(s.cfg)
+	}
+	if err != nil {
+		return err
+	}
+
+	src, err := s.findSource(opts.Profile)
+	if err != nil {
+		return err
+	}
+
+	var f *bookmarks.File
+	if opts.Rev != "" {
+		if f, err = s.loadRevision(opts.Rev, src.repoFile()); err == nil && f == nil {
+			err = fmt.Errorf("no bookmarks found at revision %s", opts.Rev)
+		}
+	} else {
+		f, err = src.profile.Load()
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case exporter.IsDir():
+		if err := exporter.WriteDir(f, opts.Output, bookmarks.WritePlainFile); err != nil {
+			return fmt.Errorf("failed to export %s: %w", exporter.Format, err)
+		}
+		fmt.Fprintf(os.Stderr, "✓ Exported %s bookmarks to %s\n", src.label, opts.Output)
+		return nil
+	case opts.Output == "":
+		return exporter.Write(f, stdout)
+	}
+
+	file, err := os.Create(opts.Output)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	if err := exporter.Write(f, file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to export %s: %w", exporter.Format, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "✓ Exported %s bookmarks to %s\n", src.label, opts.Output)
+	return nil
+}
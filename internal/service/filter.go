@@ -0,0 +1,132 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+)
+
+// SyncOptions controls a manual sync
+type SyncOptions struct {
+	DryRun  bool // Show what would be committed without writing anything
+	Explain bool // List the bookmarks left out by filters and the rule for each
+}
+
+// newRedactor compiles the redaction setting. Scanning always uses the
+// built-in rules, even if they are disabled for syncing.
+func newRedactor(cfg *config.Config, scan bool) (*bookmarks.Redactor, error) {
+	redactor, err := bookmarks.NewRedactor(bookmarks.RedactOptions{
+		DisableBuiltin: cfg.Redaction.DisableBuiltin && !scan,
+		Placeholder:    cfg.Redaction.Placeholder,
+		Params:         cfg.Redaction.Params,
+		Patterns:       cfg.Redaction.Patterns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid redaction setting: %w", err)
+	}
+	return redactor, nil
+}
+
+// newFilter compiles the filters setting
+func newFilter(cfg *config.Config) (*bookmarks.Filter, error) {
+	rules := func(rules []config.FilterRule) []bookmarks.Rule {
+		converted := make([]bookmarks.Rule, len(rules))
+		for i, r := range rules {
+			converted[i] = bookmarks.Rule(r)
+		}
+		return converted
+	}
+
+	filter, err := bookmarks.NewFilter(rules(cfg.Filters.Include), rules(cfg.Filters.Exclude))
+	if err != nil {
+		return nil, fmt.Errorf("invalid filters setting: %w", err)
+	}
+	return filter, nil
+}
+
+// loadLive reads a source's bookmarks from the browser, applies the filters
+// and redacts secrets, returning what the filters dropped
+func (s *Service) loadLive(src source) (*bookmarks.File, []bookmarks.Dropped, error) {
+	live, err := src.profile.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load bookmarks: %w", err)
+	}
+
+	var dropped []bookmarks.Dropped
+	if s.filter != nil {
+		if live, dropped, err = s.filter.Apply(live); err != nil {
+			return nil, nil, fmt.Errorf("failed to filter bookmarks: %w", err)
+		}
+	}
+
+	if s.redactor != nil {
+		var secrets []bookmarks.Secret
+		if live, secrets, err = s.redactor.Apply(live); err != nil {
+			return nil, nil, fmt.Errorf("failed to redact bookmarks: %w", err)
+		}
+		if len(secrets) > 0 {
+			log.Printf("Redacted secrets in %d bookmarks of %s", len(secrets), src.label)
+		}
+	}
+
+	return live, dropped, nil
+}
+
+// dryRun prints the changes a sync would commit for each source. Without a
+// clone of the repository every bookmark is new.
+func (s *Service) dryRun(explain, cloned bool, w io.Writer) error {
+	if !cloned {
+		fmt.Fprintln(w, "The repository isn't cloned yet, comparing with an empty one")
+	}
+	for _, src := range s.sources {
+		var previous *bookmarks.File
+		if cloned {
+			var err error
+			if previous, err = s.loadRevision("HEAD", src.repoFile()); err != nil {
+				log.Printf("Warning: failed to load previous bookmarks: %v", err)
+			}
+		}
+
+		live, dropped, err := s.loadLive(src)
+		if err != nil {
+			return fmt.Errorf("failed to sync profile %s: %w", src.label, err)
+		}
+		current, _, err := live.Canonicalize(s.cfg.Canonical.VolatileFields)
+		if err != nil {
+			return fmt.Errorf("failed to canonicalize bookmarks: %w", err)
+		}
+
+		changes := bookmarks.Diff(previous, current)
+		if len(s.sources) > 1 {
+			fmt.Fprintf(w, "%s:\n", src.label)
+		}
+		if changes.IsEmpty() {
+			fmt.Fprintln(w, "No changes to commit")
+		} else {
+			fmt.Fprintf(w, "Would commit: %s\n", changes.Summary())
+			if err := changes.WriteText(w); err != nil {
+				return err
+			}
+		}
+
+		if explain {
+			writeDropped(w, dropped)
+		}
+	}
+	return nil
+}
+
+// writeDropped lists the bookmarks left out by filters
+func writeDropped(w io.Writer, dropped []bookmarks.Dropped) {
+	if len(dropped) == 0 {
+		fmt.Fprintln(w, "No bookmarks filtered out")
+		return
+	}
+	fmt.Fprintf(w, "Filtered out %d:\n", len(dropped))
+	for _, d := range dropped {
+		fmt.Fprintf(w, "  - %s\n", d)
+	}
+}
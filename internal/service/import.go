@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+)
+
+// DefaultImportFolder is where imported bookmarks go unless chosen otherwise
+const DefaultImportFolder = "Other bookmarks/Imported"
+
+// ImportOptions controls where Import puts the bookmarks
+type ImportOptions struct {
+	Into    string // Folder to import into, e.g. "Other bookmarks/Imported"
+	Profile string // Synced profile to import into, required if several are synced
+	Force   bool   // Write even if the browser appears to be running
+}
+
+// Import merges the bookmarks of an HTML, JSON or CSV file into a browser
+// profile, backing up the current file first
+func (s *Service) Import(path string, opts ImportOptions) error {
+	sources, err := resolveSources(s.cfg)
+	if err != nil {
+		return err
+	}
+	s.sources = sources
+
+	src, err := s.findSource(opts.Profile)
+	if err != nil {
+		return err
+	}
+	if src.profile.Browser.Format != bookmarks.FormatChromium {
+		return fmt.Errorf("importing into %s is not supported", src.profile.Browser.Name)
+	}
+
+	imported, err := bookmarks.ReadImport(path)
+	if err != nil {
+		return err
+	}
+
+	if err := checkBrowserClosed(src.profile.Browser, opts.Force); err != nil {
+		return err
+	}
+
+	current, err := bookmarks.Load(src.profile.BookmarkPath)
+	if err != nil {
+		return err
+	}
+
+	into := opts.Into
+	if into == "" {
+		into = DefaultImportFolder
+	}
+	result, err := current.Import(imported, into)
+	if err != nil {
+		return err
+	}
+	if result.Added == 0 {
+		fmt.Printf("✓ Nothing to import, all %d bookmarks already exist\n", result.Skipped)
+		return nil
+	}
+
+	backupPath, err := bookmarks.WriteChromeFile(current, src.profile.BookmarkPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Imported %d bookmarks into %q (%d duplicates skipped)\n", result.Added, into, result.Skipped)
+	if backupPath != "" {
+		fmt.Printf("  Previous bookmarks backed up to: %s\n", backupPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+	"github.com/vivek-dodia/bookmarked-cli/internal/sync"
+)
+
+// IndexFileName is the manifest at the repository root listing the synced
+// profiles of every machine
+const IndexFileName = "index.json"
+
+// Index lists the bookmarks files in the repository and where they come from
+type Index struct {
+	Profiles []IndexEntry `json:"profiles"`
+}
+
+// IndexEntry describes one synced Bookmarks.json
+type IndexEntry struct {
+	Path        string    `json:"path"`     // Relative to the repository root, with forward slashes
+	Hostname    string    `json:"hostname"` // Machine that syncs it
+	Browser     string    `json:"browser"`  // Browser ID, e.g. "chrome"
+	Profile     string    `json:"profile"`  // Profile display name
+	Bookmarks   int       `json:"bookmarks"`
+	LastChanged time.Time `json:"last_changed"` // Last sync that changed the bookmarks, not updated without changes
+}
+
+// parseIndex decodes the contents of index.json
+func (s *Service) parseIndex(data []byte) (*Index, error) {
+	plain, err := s.decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	var index Index
+	if err := json.Unmarshal(plain, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", IndexFileName, err)
+	}
+	return &index, nil
+}
+
+// loadIndex reads the index from the working tree, or returns an empty one
+func (s *Service) loadIndex() (*Index, error) {
+	data, err := os.ReadFile(filepath.Join(s.gitSync.GetRepoPath(), IndexFileName))
+	if os.IsNotExist(err) {
+		return &Index{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", IndexFileName, err)
+	}
+	return s.parseIndex(data)
+}
+
+// saveIndex writes the index to the given file, sorted by path
+func (s *Service) saveIndex(index *Index, path string) error {
+	index.sort()
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", IndexFileName, err)
+	}
+	return s.writeRepoFile(path, append(data, '\n'))
+}
+
+// find returns the entry for a repository file, or nil
+func (idx *Index) find(path string) *IndexEntry {
+	for i := range idx.Profiles {
+		if idx.Profiles[i].Path == path {
+			return &idx.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// set adds or replaces the entry with the same path
+func (idx *Index) set(entry IndexEntry) {
+	if existing := idx.find(entry.Path); existing != nil {
+		*existing = entry
+		return
+	}
+	idx.Profiles = append(idx.Profiles, entry)
+}
+
+func (idx *Index) sort() {
+	sort.Slice(idx.Profiles, func(i, j int) bool {
+		return idx.Profiles[i].Path < idx.Profiles[j].Path
+	})
+}
+
+// prune removes the entries whose bookmarks file is no longer in the
+// repository, e.g. after a machine's directory was deleted
+func (idx *Index) prune(repoPath string) bool {
+	kept := idx.Profiles[:0]
+	for _, entry := range idx.Profiles {
+		if _, err := os.Stat(filepath.Join(repoPath, filepath.FromSlash(entry.Path))); err == nil {
+			kept = append(kept, entry)
+		}
+	}
+	pruned := len(kept) < len(idx.Profiles)
+	idx.Profiles = kept
+	return pruned
+}
+
+// updateIndex records the sources whose bookmarks changed in this sync, and
+// any that aren't listed yet. Unchanged profiles keep their entry so that a
+// sync without changes doesn't make a commit.
+func (s *Service) updateIndex(changed map[string]bool) error {
+	repoPath := s.gitSync.GetRepoPath()
+	index, err := s.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	modified := index.prune(repoPath)
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, src := range s.sources {
+		repoFile := filepath.ToSlash(src.repoFile())
+		if !changed[src.repoFile()] && index.find(repoFile) != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(repoPath, src.repoFile()))
+		if err != nil {
+			return fmt.Errorf("failed to read synced bookmarks: %w", err)
+		}
+		if data, err = s.decrypt(data); err != nil {
+			return err
+		}
+		f, err := bookmarks.Parse(data)
+		if err != nil {
+			return err
+		}
+
+		index.set(IndexEntry{
+			Path:        repoFile,
+			Hostname:    hostname(),
+			Browser:     src.profile.Browser.ID,
+			Profile:     src.profile.Name,
+			Bookmarks:   len(f.Bookmarks()),
+			LastChanged: now,
+		})
+		modified = true
+	}
+
+	if !modified {
+		return nil
+	}
+	return s.saveIndex(index, filepath.Join(repoPath, IndexFileName))
+}
+
+// mergeIndex combines the index of both sides of a merge. Entries changed on
+// both keep the more recent change.
+func (s *Service) mergeIndex(repoPath string, c sync.FileConflict) error {
+	merged := &Index{}
+	for _, data := range [][]byte{c.Theirs, c.Ours} {
+		if data == nil {
+			continue
+		}
+		index, err := s.parseIndex(data)
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %w", c.Path, err)
+		}
+		for _, entry := range index.Profiles {
+			if existing := merged.find(entry.Path); existing != nil && existing.LastChanged.After(entry.LastChanged) {
+				continue
+			}
+			merged.set(entry)
+		}
+	}
+
+	log.Printf("Merged %s", c.Path)
+	return s.saveIndex(merged, filepath.Join(repoPath, filepath.FromSlash(c.Path)))
+}
+
+// readRemoteIndex returns the index of the remote branch if it was fetched,
+// otherwise the one of the local branch. Without an index it returns nil.
+func (s *Service) readRemoteIndex() (*Index, error) {
+	for _, rev := range []string{s.gitSync.RemoteRevision(), "HEAD"} {
+		data, err := s.gitSync.ReadFile(rev, IndexFileName)
+		if err != nil {
+			// Not fetched yet, or nothing synced there
+			continue
+		}
+		return s.parseIndex(data)
+	}
+	return nil, nil
+}
@@ -1,24 +1,54 @@
 package service
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"os"
 	"runtime"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
 )
 
-// Install installs the service for the current platform
-func Install() error {
+// Install installs the service for the current platform. The service runs
+// with the config file in use and logs to the data directory.
+func Install(cfg *config.Config) error {
+	configPath, err := config.GetConfigPath()
+	if err != nil {
+		return err
+	}
+	logPath := cfg.ServiceLogPath()
+
 	switch runtime.GOOS {
 	case "windows":
-		return installWindows()
+		return installWindows(configPath, logPath)
 	case "darwin":
-		return installMacOS()
+		return installMacOS(configPath, logPath)
 	case "linux":
-		return installLinux()
+		return installLinux(configPath, logPath)
 	default:
 		return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
 	}
 }
 
+// instanceSuffix is appended to the service names of an instance with its
+// own config file, so that installing it doesn't replace the service of
+// another instance. The default config file keeps the plain names.
+func instanceSuffix() (string, error) {
+	configPath, err := config.GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	defaultPath, err := config.DefaultConfigPath()
+	if err != nil {
+		return "", err
+	}
+	if configPath == defaultPath {
+		return "", nil
+	}
+	sum := sha256.Sum256([]byte(configPath))
+	return fmt.Sprintf("-%x", sum[:4]), nil
+}
+
 // Uninstall removes the service for the current platform
 func Uninstall() error {
 	switch runtime.GOOS {
@@ -33,16 +63,22 @@ func Uninstall() error {
 	}
 }
 
-// Status checks the service status for the current platform
-func Status() error {
+// Status checks the service status for the current platform. If cfg is not
+// nil commits waiting to be pushed are reported as well.
+func Status(cfg *config.Config) error {
+	var err error
 	switch runtime.GOOS {
 	case "windows":
-		return statusWindows()
+		err = statusWindows()
 	case "darwin":
-		return statusMacOS()
+		err = statusMacOS()
 	case "linux":
-		return statusLinux()
+		err = statusLinux()
 	default:
-		return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		err = fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	}
+	if err != nil || cfg == nil {
+		return err
 	}
+	return writePushStatus(cfg, os.Stdout)
 }
@@ -15,11 +15,13 @@ const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
 <plist version="1.0">
 <dict>
     <key>Label</key>
-    <string>com.bookmarked.sync</string>
+    <string>{{.Label}}</string>
     <key>ProgramArguments</key>
     <array>
         <string>{{.ExePath}}</string>
         <string>start</string>
+        <string>--config</string>
+        <string>{{.ConfigPath}}</string>
     </array>
     <key>RunAtLoad</key>
     <true/>
@@ -33,34 +35,45 @@ const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
 </plist>
 `
 
-func installMacOS() error {
-	// Get current executable path
-	exePath, err := os.Executable()
+// launchAgent returns the label of this instance's launch agent and the
+// path of its plist
+func launchAgent() (string, string, error) {
+	suffix, err := instanceSuffix()
 	if err != nil {
-		return fmt.Errorf("failed to get executable path: %w", err)
+		return "", "", err
 	}
-
-	// Get user home directory
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return "", "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	label := "com.bookmarked.sync" + suffix
+	return label, filepath.Join(homeDir, "Library", "LaunchAgents", label+".plist"), nil
+}
+
+func installMacOS(configPath, logPath string) error {
+	label, plistPath, err := launchAgent()
+	if err != nil {
+		return err
+	}
+
+	// Get current executable path
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
 	}
 
 	// Create LaunchAgents directory
-	launchAgentsDir := filepath.Join(homeDir, "Library", "LaunchAgents")
-	if err := os.MkdirAll(launchAgentsDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
 		return fmt.Errorf("failed to create LaunchAgents directory: %w", err)
 	}
 
 	// Create log directory
-	logDir := filepath.Join(homeDir, ".bookmarked")
+	logDir := filepath.Dir(logPath)
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return fmt.Errorf("failed to create log directory: %w", err)
 	}
 
-	plistPath := filepath.Join(launchAgentsDir, "com.bookmarked.sync.plist")
-	logPath := filepath.Join(logDir, "bookmarked.log")
-
 	// Create plist file
 	tmpl, err := template.New("plist").Parse(plistTemplate)
 	if err != nil {
@@ -74,11 +87,15 @@ func installMacOS() error {
 	defer file.Close()
 
 	data := struct {
-		ExePath string
-		LogPath string
+		Label      string
+		ExePath    string
+		ConfigPath string
+		LogPath    string
 	}{
-		ExePath: exePath,
-		LogPath: logPath,
+		Label:      label,
+		ExePath:    exePath,
+		ConfigPath: configPath,
+		LogPath:    logPath,
 	}
 
 	if err := tmpl.Execute(file, data); err != nil {
@@ -93,6 +110,7 @@ func installMacOS() error {
 
 	fmt.Println("✓ Service installed successfully")
 	fmt.Println("  The service will start automatically when you log in")
+	fmt.Printf("  Launch agent: %s\n", label)
 	fmt.Printf("  Logs: %s\n", logPath)
 	fmt.Println("\nTo start now, run: bookmarked start")
 	fmt.Println("To check status, run: bookmarked status")
@@ -101,13 +119,11 @@ func installMacOS() error {
 }
 
 func uninstallMacOS() error {
-	homeDir, err := os.UserHomeDir()
+	_, plistPath, err := launchAgent()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return err
 	}
 
-	plistPath := filepath.Join(homeDir, "Library", "LaunchAgents", "com.bookmarked.sync.plist")
-
 	// Unload the launch agent
 	cmd := exec.Command("launchctl", "unload", plistPath)
 	cmd.Run() // Ignore errors if not loaded
@@ -122,20 +138,18 @@ func uninstallMacOS() error {
 }
 
 func statusMacOS() error {
-	homeDir, err := os.UserHomeDir()
+	label, plistPath, err := launchAgent()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return err
 	}
 
-	plistPath := filepath.Join(homeDir, "Library", "LaunchAgents", "com.bookmarked.sync.plist")
-
 	if _, err := os.Stat(plistPath); os.IsNotExist(err) {
 		fmt.Println("✗ Service is not installed")
 		return nil
 	}
 
 	// Check if loaded
-	cmd := exec.Command("launchctl", "list", "com.bookmarked.sync")
+	cmd := exec.Command("launchctl", "list", label)
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
@@ -151,11 +165,11 @@ func statusMacOS() error {
 
 
 // Stub functions for other platforms
-func installWindows() error {
+func installWindows(configPath, logPath string) error {
 	return fmt.Errorf("Windows installation not available on macOS")
 }
 
-func installLinux() error {
+func installLinux(configPath, logPath string) error {
 	return fmt.Errorf("Linux installation not available on macOS")
 }
 
@@ -16,7 +16,7 @@ After=network.target
 
 [Service]
 Type=simple
-ExecStart={{.ExePath}} start
+ExecStart={{.ExePath}} start --config "{{.ConfigPath}}"
 Restart=on-failure
 RestartSec=10
 
@@ -24,7 +24,21 @@ RestartSec=10
 WantedBy=default.target
 `
 
-func installLinux() error {
+// systemdUnit returns the name of the systemd user unit of this instance
+func systemdUnit() (string, error) {
+	suffix, err := instanceSuffix()
+	if err != nil {
+		return "", err
+	}
+	return "bookmarked" + suffix + ".service", nil
+}
+
+func installLinux(configPath, logPath string) error {
+	unit, err := systemdUnit()
+	if err != nil {
+		return err
+	}
+
 	// Get current executable path
 	exePath, err := os.Executable()
 	if err != nil {
@@ -44,13 +58,12 @@ func installLinux() error {
 	}
 
 	// Create log directory
-	logDir := filepath.Join(homeDir, ".bookmarked")
+	logDir := filepath.Dir(logPath)
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return fmt.Errorf("failed to create log directory: %w", err)
 	}
 
-	servicePath := filepath.Join(systemdDir, "bookmarked.service")
-	logPath := filepath.Join(logDir, "bookmarked.log")
+	servicePath := filepath.Join(systemdDir, unit)
 
 	// Create service file
 	tmpl, err := template.New("service").Parse(systemdTemplate)
@@ -65,9 +78,11 @@ func installLinux() error {
 	defer file.Close()
 
 	data := struct {
-		ExePath string
+		ExePath    string
+		ConfigPath string
 	}{
-		ExePath: exePath,
+		ExePath:    exePath,
+		ConfigPath: configPath,
 	}
 
 	if err := tmpl.Execute(file, data); err != nil {
@@ -78,7 +93,7 @@ func installLinux() error {
 	exec.Command("systemctl", "--user", "daemon-reload").Run()
 
 	// Enable the service
-	cmd := exec.Command("systemctl", "--user", "enable", "bookmarked.service")
+	cmd := exec.Command("systemctl", "--user", "enable", unit)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to enable service: %w\nOutput: %s", err, string(output))
 	}
@@ -86,19 +101,24 @@ func installLinux() error {
 	fmt.Println("✓ Service installed successfully")
 	fmt.Println("  The service will start automatically when you log in")
 	fmt.Printf("  Logs: %s\n", logPath)
-	fmt.Println("\nTo start now, run: systemctl --user start bookmarked")
-	fmt.Println("To check status, run: systemctl --user status bookmarked")
+	fmt.Printf("\nTo start now, run: systemctl --user start %s\n", unit)
+	fmt.Printf("To check status, run: systemctl --user status %s\n", unit)
 	fmt.Println("Or use: bookmarked status")
 
 	return nil
 }
 
 func uninstallLinux() error {
+	unit, err := systemdUnit()
+	if err != nil {
+		return err
+	}
+
 	// Stop the service
-	exec.Command("systemctl", "--user", "stop", "bookmarked.service").Run()
+	exec.Command("systemctl", "--user", "stop", unit).Run()
 
 	// Disable the service
-	exec.Command("systemctl", "--user", "disable", "bookmarked.service").Run()
+	exec.Command("systemctl", "--user", "disable", unit).Run()
 
 	// Remove service file
 	homeDir, err := os.UserHomeDir()
@@ -106,7 +126,7 @@ func uninstallLinux() error {
 		return fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	servicePath := filepath.Join(homeDir, ".config", "systemd", "user", "bookmarked.service")
+	servicePath := filepath.Join(homeDir, ".config", "systemd", "user", unit)
 	if err := os.Remove(servicePath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove service file: %w", err)
 	}
@@ -119,7 +139,12 @@ func uninstallLinux() error {
 }
 
 func statusLinux() error {
-	cmd := exec.Command("systemctl", "--user", "status", "bookmarked.service")
+	unit, err := systemdUnit()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("systemctl", "--user", "status", unit)
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
@@ -134,11 +159,11 @@ func statusLinux() error {
 
 
 // Stub functions for other platforms
-func installWindows() error {
+func installWindows(configPath, logPath string) error {
 	return fmt.Errorf("Windows installation not available on Linux")
 }
 
-func installMacOS() error {
+func installMacOS(configPath, logPath string) error {
 	return fmt.Errorf("macOS installation not available on Linux")
 }
 
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+)
+
+func TestInstanceSuffix(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv(config.ConfigEnvVar, "")
+	defer config.SetConfigPath("")
+
+	suffixFor := func(path string) string {
+		t.Helper()
+		config.SetConfigPath(path)
+		suffix, err := instanceSuffix()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return suffix
+	}
+
+	if got := suffixFor(""); got != "" {
+		t.Errorf("default config: suffix %q, want none", got)
+	}
+	if got := suffixFor(filepath.Join(home, ".bookmarked", "config.yaml")); got != "" {
+		t.Errorf("default config given with --config: suffix %q, want none", got)
+	}
+
+	work := suffixFor(filepath.Join(home, ".bookmarked-work", "config.yaml"))
+	personal := suffixFor(filepath.Join(home, ".bookmarked-personal", "config.yaml"))
+	if work == "" || personal == "" || work == personal {
+		t.Errorf("suffixes %q and %q, want two different ones", work, personal)
+	}
+	if again := suffixFor(filepath.Join(home, ".bookmarked-work", "config.yaml")); again != work {
+		t.Errorf("suffix changed from %q to %q", work, again)
+	}
+}
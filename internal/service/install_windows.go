@@ -9,34 +9,38 @@ import (
 	"path/filepath"
 )
 
-func installWindows() error {
-	// Get current executable path
-	exePath, err := os.Executable()
+// scheduledTask returns the name of this instance's scheduled task
+func scheduledTask() (string, error) {
+	suffix, err := instanceSuffix()
 	if err != nil {
-		return fmt.Errorf("failed to get executable path: %w", err)
+		return "", err
 	}
+	return "Bookmarked" + suffix, nil
+}
 
-	// Get user home directory
-	homeDir, err := os.UserHomeDir()
+func installWindows(configPath, logPath string) error {
+	taskName, err := scheduledTask()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return err
+	}
+
+	// Get current executable path
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
 	}
 
 	// Create log directory
-	logDir := filepath.Join(homeDir, ".bookmarked")
+	logDir := filepath.Dir(logPath)
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return fmt.Errorf("failed to create log directory: %w", err)
 	}
 
-	logPath := filepath.Join(logDir, "bookmarked.log")
-
-	// Create a scheduled task that runs at login
-	taskName := "Bookmarked"
 
 	// Delete existing task if it exists
 	exec.Command("schtasks", "/Delete", "/TN", taskName, "/F").Run()
 
-	// Create XML configuration for hidden task
+	// Create XML configuration for a hidden task that runs at login
 	xmlContent := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
 <Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
   <RegistrationInfo>
@@ -63,10 +67,10 @@ func installWindows() error {
   <Actions Context="Author">
     <Exec>
       <Command>%s</Command>
-      <Arguments>start</Arguments>
+      <Arguments>start --config "%s"</Arguments>
     </Exec>
   </Actions>
-</Task>`, exePath)
+</Task>`, exePath, configPath)
 
 	// Save XML to temp file
 	xmlPath := filepath.Join(logDir, "task.xml")
@@ -90,6 +94,7 @@ func installWindows() error {
 	fmt.Println("✓ Service installed successfully")
 	fmt.Println("  The service will run hidden in the background")
 	fmt.Println("  It will start automatically when you log in")
+	fmt.Printf("  Scheduled task: %s\n", taskName)
 	fmt.Printf("  Logs: %s\n", logPath)
 	fmt.Println("\nTo start now, run: bookmarked start")
 	fmt.Println("To check status, run: bookmarked status")
@@ -98,7 +103,10 @@ func installWindows() error {
 }
 
 func uninstallWindows() error {
-	taskName := "Bookmarked"
+	taskName, err := scheduledTask()
+	if err != nil {
+		return err
+	}
 
 	cmd := exec.Command("schtasks", "/Delete", "/TN", taskName, "/F")
 	output, err := cmd.CombinedOutput()
@@ -111,7 +119,10 @@ func uninstallWindows() error {
 }
 
 func statusWindows() error {
-	taskName := "Bookmarked"
+	taskName, err := scheduledTask()
+	if err != nil {
+		return err
+	}
 
 	cmd := exec.Command("schtasks", "/Query", "/TN", taskName, "/FO", "LIST", "/V")
 	output, err := cmd.CombinedOutput()
@@ -129,11 +140,11 @@ func statusWindows() error {
 
 
 // Stub functions for other platforms
-func installMacOS() error {
+func installMacOS(configPath, logPath string) error {
 	return fmt.Errorf("macOS installation not available on Windows")
 }
 
-func installLinux() error {
+func installLinux(configPath, logPath string) error {
 	return fmt.Errorf("Linux installation not available on Windows")
 }
 
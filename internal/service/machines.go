@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"text/tabwriter"
+)
+
+// Machines lists the machines and profiles synced to the repository, from
+// the index of the remote branch, and when the bookmarks of each last changed
+func (s *Service) Machines(w io.Writer) error {
+	if err := s.openRepo(); err != nil {
+		return err
+	}
+	if _, err := s.gitSync.Fetch(); err != nil {
+		log.Printf("Warning: Fetch failed, listing the local copy: %v", err)
+	}
+
+	index, err := s.readRemoteIndex()
+	if err != nil {
+		return err
+	}
+	if index == nil || len(index.Profiles) == 0 {
+		fmt.Fprintf(w, "No %s in the repository yet, it is written on the next sync\n", IndexFileName)
+		return nil
+	}
+
+	entries := index.Profiles
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Hostname < entries[j].Hostname
+	})
+
+	this := hostname()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MACHINE\tBROWSER\tPROFILE\tBOOKMARKS\tLAST CHANGED\tPATH")
+	for _, e := range entries {
+		machine := e.Hostname
+		if machine == this {
+			machine += " (this machine)"
+		}
+		changed := "-" // Written by an older version
+		if !e.LastChanged.IsZero() {
+			changed = e.LastChanged.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", machine, e.Browser, e.Profile, e.Bookmarks, changed, e.Path)
+	}
+	return tw.Flush()
+}
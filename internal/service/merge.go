@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+	"github.com/vivek-dodia/bookmarked-cli/internal/sync"
+)
+
+// ConflictReportName is the file in the data directory that merge conflicts
+// are appended to
+const ConflictReportName = "conflicts.txt"
+
+// maxPushAttempts limits how often the remote is fetched again when it
+// changes between fetching and pushing
+const maxPushAttempts = 3
+
+// push integrates the fetched remote commits and pushes local commits
+func (s *Service) push() error {
+	for attempt := 1; ; attempt++ {
+		if _, err := s.gitSync.Integrate(s.resolveConflicts); err != nil {
+			return fmt.Errorf("failed to integrate remote changes: %w", err)
+		}
+
+		ahead, err := s.gitSync.Ahead()
+		if err != nil {
+			return err
+		}
+		if !ahead {
+			return nil
+		}
+
+		err = s.gitSync.Push()
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, sync.ErrPushRejected) {
+			return err
+		}
+		if attempt == maxPushAttempts {
+			return fmt.Errorf("failed to push: %w", err)
+		}
+
+		log.Println("Remote branch changed during the sync, fetching again")
+		if _, err := s.gitSync.Fetch(); err != nil {
+			return err
+		}
+	}
+}
+
+// resolveConflicts merges synced bookmarks changed both locally and
+// remotely node by node and writes the other formats from the result. The
+// index keeps the entries of both sides, other files the local version.
+// Conflicting changes to the same bookmark are appended to the conflict
+// report.
+func (s *Service) resolveConflicts(repoPath string, conflicts []sync.FileConflict) error {
+	var merges []sync.FileConflict
+	mergedDirs := make(map[string]bool)
+	for _, c := range conflicts {
+		if path.Base(c.Path) == bookmarks.RepoFileName {
+			merges = append(merges, c)
+			mergedDirs[path.Dir(c.Path)] = true
+		}
+	}
+
+	for _, c := range conflicts {
+		if path.Base(c.Path) == bookmarks.RepoFileName {
+			continue
+		}
+		if c.Path == IndexFileName {
+			if err := s.mergeIndex(repoPath, c); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isGenerated(c.Path, mergedDirs) {
+			log.Printf("Keeping the local version of %s, changed locally and remotely", c.Path)
+		}
+
+		fullPath := filepath.Join(repoPath, filepath.FromSlash(c.Path))
+		if c.Ours == nil {
+			if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", c.Path, err)
+			}
+			continue
+		}
+		if err := bookmarks.WritePlainFile(fullPath, c.Ours); err != nil {
+			return err
+		}
+	}
+
+	rw := s.repoWriter()
+	for _, c := range merges {
+		versions := make([]*bookmarks.File, 3)
+		for i, data := range [][]byte{c.Base, c.Ours, c.Theirs} {
+			if data == nil {
+				continue
+			}
+			plain, err := s.decrypt(data)
+			if err != nil {
+				return fmt.Errorf("failed to merge %s: %w", c.Path, err)
+			}
+			if versions[i], err = bookmarks.Parse(plain); err != nil {
+				return fmt.Errorf("failed to merge %s: %w", c.Path, err)
+			}
+		}
+
+		merged, bookmarkConflicts, err := bookmarks.Merge(versions[0], versions[1], versions[2])
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %w", c.Path, err)
+		}
+		dir := filepath.Join(repoPath, filepath.FromSlash(path.Dir(c.Path)))
+		if err := rw.Write(merged, dir); err != nil {
+			return fmt.Errorf("failed to write merged %s: %w", c.Path, err)
+		}
+
+		if len(bookmarkConflicts) == 0 {
+			log.Printf("Merged %s", c.Path)
+			continue
+		}
+		reportPath, err := s.reportConflicts(c.Path, bookmarkConflicts)
+		if err != nil {
+			return err
+		}
+		log.Printf("Merged %s with %d conflicting changes, kept the local ones, see %s", c.Path, len(bookmarkConflicts), reportPath)
+	}
+	return nil
+}
+
+// reportConflicts appends the conflicts of a merged file to the conflict
+// report and returns its path
+func (s *Service) reportConflicts(repoFile string, conflicts []bookmarks.Conflict) (string, error) {
+	reportPath := filepath.Join(s.cfg.DataDir, ConflictReportName)
+	f, err := os.OpenFile(reportPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open conflict report: %w", err)
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "%s %s\n", time.Now().Format(time.RFC3339), repoFile)
+	for _, c := range conflicts {
+		fmt.Fprintf(f, "  %s\n", c.String())
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write conflict report: %w", err)
+	}
+	return reportPath, nil
+}
+
+// isGenerated reports whether a file is written from the Bookmarks.json of
+// one of the given directories, e.g. bookmarks.html next to it
+func isGenerated(file string, dirs map[string]bool) bool {
+	for dir := file; dir != "." && dir != "/"; {
+		name := path.Base(dir)
+		dir = path.Dir(dir)
+		if !dirs[dir] {
+			continue
+		}
+		if name == bookmarks.VolatileFileName {
+			return true
+		}
+		for _, e := range bookmarks.Exporters {
+			if name == e.FileName {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+)
+
+// source is a browser profile synced to a directory of the repository
+type source struct {
+	profile bookmarks.Profile
+	repoDir string // Relative to the repository root, "" for the root itself
+	label   string // Name used in logs and commit messages
+}
+
+// repoFile returns the path of the source's bookmarks file in the repository
+func (src source) repoFile() string {
+	return filepath.Join(src.repoDir, bookmarks.RepoFileName)
+}
+
+// warnFirefoxLag warns that a Firefox source lags behind the browser, since
+// it is read from the backups Firefox writes about once a day
+func warnFirefoxLag(src source) {
+	backupTime, err := bookmarks.FirefoxBackupTime(src.profile.BookmarkPath)
+	if err != nil {
+		return // reported when the bookmarks are loaded
+	}
+	log.Printf("Warning: %s bookmarks are read from Firefox's daily backup, the newest is from %s (%s ago); later changes are synced once Firefox writes the next one",
+		src.label, backupTime.Format("2006-01-02 15:04"), time.Since(backupTime).Round(time.Minute))
+}
+
+// resolveBrowsers returns the configured browsers. auto selects the first
+// browser with bookmarks on this machine.
+func resolveBrowsers(cfg *config.Config) ([]*bookmarks.Browser, error) {
+	var browsers []*bookmarks.Browser
+	seen := make(map[*bookmarks.Browser]bool)
+
+	for _, id := range cfg.Browser {
+		var browser *bookmarks.Browser
+		if strings.EqualFold(id, config.AutoBrowser) {
+			detected := bookmarks.DetectBrowsers()
+			if len(detected) == 0 {
+				return nil, fmt.Errorf("no supported browser with bookmarks found, run 'bookmarked browsers' to see where they are looked for")
+			}
+			browser = detected[0]
+		} else {
+			var err error
+			if browser, err = bookmarks.FindBrowser(id); err != nil {
+				return nil, err
+			}
+		}
+
+		if !seen[browser] {
+			seen[browser] = true
+			browsers = append(browsers, browser)
+		}
+	}
+
+	return browsers, nil
+}
+
+// resolveSources determines which profiles to sync from the browser and
+// profiles settings. Without profiles only the default profile is synced,
+// stored at the repository root as before profiles were supported. With
+// several browsers each one is stored under a directory named after it.
+func resolveSources(cfg *config.Config) ([]source, error) {
+	browsers, err := resolveBrowsers(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []source
+	matched := make(map[string]bool)
+	for _, browser := range browsers {
+		var prefix string
+		if len(browsers) > 1 {
+			prefix = browser.ID
+		}
+
+		if len(cfg.Profiles) == 0 {
+			p, err := browser.DefaultProfile()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get bookmark path: %w", err)
+			}
+			if !p.HasBookmarks {
+				return nil, fmt.Errorf("%s bookmarks not found at: %s", browser.Name, p.BookmarkPath)
+			}
+			sources = append(sources, source{profile: p, repoDir: prefix})
+			continue
+		}
+
+		selected, err := selectProfiles(browser, cfg.Profiles, matched)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, profileSources(selected, prefix)...)
+	}
+
+	for i := range sources {
+		sources[i].label = sources[i].profile.Name
+		if len(browsers) > 1 {
+			sources[i].label = fmt.Sprintf("%s (%s)", sources[i].profile.Browser.Name, sources[i].profile.Name)
+		}
+	}
+
+	// A named profile only needs to exist in one of the browsers
+	for _, name := range cfg.Profiles {
+		if !matched[name] {
+			return nil, fmt.Errorf("profile %q not found, run 'bookmarked profiles' to list them", name)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no profiles with bookmarks found")
+	}
+	if cfg.PathTemplate != "" {
+		if err := applyPathTemplate(cfg.PathTemplate, sources); err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// pathData is the data available to the path_template template
+type pathData struct {
+	Hostname string // Name of this machine
+	Browser  string // Browser ID, e.g. "chrome"
+	Profile  string // Profile display name, or directory if it has none
+}
+
+// applyPathTemplate stores each source where path_template puts its
+// Bookmarks.json instead of the default layout
+func applyPathTemplate(text string, sources []source) error {
+	tmpl, err := template.New("path_template").Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse path_template: %w", err)
+	}
+
+	seen := make(map[string]string)
+	for i, src := range sources {
+		data := pathData{
+			Hostname: bookmarks.SafePathName(hostname()),
+			Browser:  src.profile.Browser.ID,
+			Profile:  src.profile.SafeName(),
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return fmt.Errorf("failed to render path_template: %w", err)
+		}
+		file := path.Clean(filepath.ToSlash(buf.String()))
+		if !filepath.IsLocal(filepath.FromSlash(file)) || path.Base(file) != bookmarks.RepoFileName {
+			return fmt.Errorf("path_template gives %q for %s, it must be a path to a %s inside the repository", file, src.label, bookmarks.RepoFileName)
+		}
+		if other, ok := seen[strings.ToLower(file)]; ok {
+			return fmt.Errorf("path_template stores %s and %s in the same file %s, add {{.Browser}} or {{.Profile}} to it", other, src.label, file)
+		}
+		seen[strings.ToLower(file)] = src.label
+
+		sources[i].repoDir = filepath.FromSlash(path.Dir(file))
+		if sources[i].repoDir == "." {
+			sources[i].repoDir = ""
+		}
+	}
+	return nil
+}
+
+// selectProfiles returns the browser's profiles matching the names from the
+// profiles setting, recording which names matched
+func selectProfiles(browser *bookmarks.Browser, names []string, matched map[string]bool) ([]bookmarks.Profile, error) {
+	profiles, err := browser.ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []bookmarks.Profile
+	for _, name := range names {
+		if strings.EqualFold(name, config.AllProfiles) {
+			for _, p := range profiles {
+				if p.HasBookmarks {
+					selected = append(selected, p)
+				}
+			}
+			matched[name] = true
+			continue
+		}
+
+		p, err := bookmarks.FindProfile(profiles, name)
+		if err != nil {
+			continue
+		}
+		if !p.HasBookmarks {
+			return nil, fmt.Errorf("%s bookmarks not found at: %s", browser.Name, p.BookmarkPath)
+		}
+		selected = append(selected, p)
+		matched[name] = true
+	}
+
+	return selected, nil
+}
+
+// profileSources stores each profile under profiles/<name> below prefix
+func profileSources(profiles []bookmarks.Profile, prefix string) []source {
+	var sources []source
+	seenDirs := make(map[string]bool)
+	seenNames := make(map[string]bool)
+	for _, p := range profiles {
+		if seenDirs[p.Dir] {
+			continue
+		}
+		seenDirs[p.Dir] = true
+
+		// Profiles may share a display name, fall back to the directory
+		name := p.SafeName()
+		if seenNames[strings.ToLower(name)] {
+			name = p.SafeDir()
+		}
+		seenNames[strings.ToLower(name)] = true
+
+		sources = append(sources, source{
+			profile: p,
+			repoDir: filepath.Join(prefix, "profiles", name),
+		})
+	}
+	return sources
+}
+
+// findSource returns the synced source matching a profile name, or the only
+// source if name is empty. The name may be prefixed with a browser ID, e.g.
+// "firefox:default-release", to pick between browsers.
+func (s *Service) findSource(name string) (source, error) {
+	if name == "" {
+		if len(s.sources) > 1 {
+			return source{}, fmt.Errorf("several profiles are synced, choose one with --profile")
+		}
+		return s.sources[0], nil
+	}
+
+	browserID, profileName, hasBrowser := strings.Cut(name, ":")
+	if !hasBrowser {
+		profileName = name
+	}
+
+	var found []source
+	for _, src := range s.sources {
+		p := src.profile
+		if hasBrowser && !strings.EqualFold(p.Browser.ID, browserID) {
+			continue
+		}
+		if strings.EqualFold(p.Name, profileName) || strings.EqualFold(p.Dir, profileName) {
+			found = append(found, src)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return source{}, fmt.Errorf("profile %q is not synced, check the browser and profiles settings", name)
+	case 1:
+		return found[0], nil
+	default:
+		return source{}, fmt.Errorf("profile %q exists in several browsers, use <browser>:<profile>, e.g. %s:%s", name, found[0].profile.Browser.ID, profileName)
+	}
+}
+
+// Profiles lists the browser's profiles on this machine. If cfg is not nil
+// the profiles it syncs are marked.
+func Profiles(browser *bookmarks.Browser, cfg *config.Config, w io.Writer) error {
+	profiles, err := browser.ListProfiles()
+	if err != nil {
+		return err
+	}
+
+	synced := make(map[string]string)
+	if cfg != nil {
+		if sources, err := resolveSources(cfg); err == nil {
+			for _, src := range sources {
+				if src.profile.Browser == browser {
+					synced[src.profile.Dir] = filepath.ToSlash(src.repoFile())
+				}
+			}
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tDIRECTORY\tBOOKMARKS\tSYNCED TO")
+	for _, p := range profiles {
+		hasBookmarks := "no"
+		if p.HasBookmarks {
+			hasBookmarks = "yes"
+		}
+		syncedTo := synced[p.Dir]
+		if syncedTo == "" {
+			syncedTo = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Name, p.Dir, hasBookmarks, syncedTo)
+	}
+	return tw.Flush()
+}
+
+// Browsers lists the supported browsers and whether they were found here
+func Browsers(w io.Writer) error {
+	detected := make(map[*bookmarks.Browser]bool)
+	for _, b := range bookmarks.DetectBrowsers() {
+		detected[b] = true
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BROWSER\tID\tBOOKMARKS\tDATA DIRECTORY")
+	for _, b := range bookmarks.Browsers {
+		found := "no"
+		if detected[b] {
+			found = "yes"
+		}
+		dir, err := b.UserDataDir()
+		if err != nil {
+			dir = err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", b.Name, b.ID, found, dir)
+	}
+	return tw.Flush()
+}
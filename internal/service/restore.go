@@ -0,0 +1,113 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+)
+
+// RestoreOptions controls what Restore writes back into the browser
+type RestoreOptions struct {
+	Folder  string // Only restore this folder, e.g. "Bookmarks bar/Work"
+	Profile string // Synced profile to restore, required if several are synced
+	Force   bool   // Write even if Chrome appears to be running
+}
+
+// Restore writes the bookmarks synced at a revision back into a browser
+// profile, backing up the current file first
+func (s *Service) Restore(rev string, opts RestoreOptions) error {
+	if err := s.setup(); err != nil {
+		return err
+	}
+
+	src, err := s.findSource(opts.Profile)
+	if err != nil {
+		return err
+	}
+	if src.profile.Browser.Format != bookmarks.FormatChromium {
+		return fmt.Errorf("restoring into %s is not supported", src.profile.Browser.Name)
+	}
+	bookmarkPath := src.profile.BookmarkPath
+
+	restored, err := s.loadRevision(rev, src.repoFile())
+	if err != nil {
+		return err
+	}
+	if restored == nil {
+		return fmt.Errorf("no bookmarks found at revision %s", rev)
+	}
+
+	if err := checkBrowserClosed(src.profile.Browser, opts.Force); err != nil {
+		return err
+	}
+
+	result, err := s.restoreInto(src, restored, opts.Folder)
+	if err != nil {
+		return err
+	}
+
+	backupPath, err := bookmarks.WriteChromeFile(result, bookmarkPath)
+	if err != nil {
+		return err
+	}
+
+	what := "bookmarks"
+	if opts.Folder != "" {
+		what = fmt.Sprintf("folder %q", opts.Folder)
+	}
+	fmt.Printf("✓ Restored %s from %s into %s\n", what, rev, bookmarkPath)
+	if backupPath != "" {
+		fmt.Printf("  Previous bookmarks backed up to: %s\n", backupPath)
+	}
+
+	return nil
+}
+
+// restoreInto returns the source's live bookmarks with the restored version,
+// or one folder of it, applied. The repository never sees filtered
+// bookmarks, the secrets redacted from URLs or volatile fields, so the
+// restored version is merged as a change from the bookmarks as they would be
+// synced now, which keeps those as they are.
+func (s *Service) restoreInto(src source, restored *bookmarks.File, folder string) (*bookmarks.File, error) {
+	live, err := src.profile.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bookmarks: %w", err)
+	}
+	filtered, _, err := s.loadLive(src)
+	if err != nil {
+		return nil, err
+	}
+	local, _, err := filtered.Canonicalize(s.cfg.Canonical.VolatileFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize bookmarks: %w", err)
+	}
+
+	target := restored
+	if folder != "" {
+		if target, err = local.Clone(); err != nil {
+			return nil, err
+		}
+		if err := target.RestoreFolder(restored, folder); err != nil {
+			return nil, err
+		}
+	}
+
+	result, _, err := bookmarks.Merge(local, live, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore bookmarks: %w", err)
+	}
+	return result, nil
+}
+
+// checkBrowserClosed returns an error if the browser is running, unless forced
+func checkBrowserClosed(browser *bookmarks.Browser, force bool) error {
+	running, err := browser.IsRunning()
+	if err != nil {
+		return err
+	}
+	if running && !force {
+		return fmt.Errorf("%s is running and would overwrite the restored bookmarks on exit; close it first or use --force", browser.Name)
+	}
+
+	return nil
+}
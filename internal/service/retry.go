@@ -0,0 +1,255 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+)
+
+// PushStateName is the file in the data directory that records local
+// commits that couldn't be pushed yet
+const PushStateName = "unpushed.json"
+
+// Delays between push retries, doubling from the first to the last
+const (
+	minRetryDelay = 10 * time.Second
+	maxRetryDelay = 15 * time.Minute
+)
+
+// shutdownPushTimeout is how long the service waits for the push of the
+// local commits when it stops
+const shutdownPushTimeout = 30 * time.Second
+
+// pushState describes local commits waiting to be pushed
+type pushState struct {
+	Commits     int       `json:"commits"`      // Local commits the remote doesn't have
+	Since       time.Time `json:"since"`        // First failed push
+	Attempts    int       `json:"attempts"`     // Failed pushes since then
+	LastError   string    `json:"last_error"`   // Error of the last attempt
+	NextAttempt time.Time `json:"next_attempt"` // When the service retries
+}
+
+// loadPushState reads the unpushed state, nil if everything was pushed
+func loadPushState(dataDir string) (*pushState, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, PushStateName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read push state: %w", err)
+	}
+	var state pushState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse push state: %w", err)
+	}
+	return &state, nil
+}
+
+// retryDelay returns the delay before the next push after the given number
+// of failed attempts: exponential backoff with jitter, so that machines that
+// went offline together don't retry in lockstep
+func retryDelay(attempts int) time.Duration {
+	delay := maxRetryDelay
+	if shift := attempts - 1; shift < 16 && minRetryDelay<<shift < maxRetryDelay {
+		delay = minRetryDelay << shift
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// pushFailed records that the local commits couldn't be pushed and
+// schedules a retry. The returned error describes the failure.
+func (s *Service) pushFailed(pushErr error) error {
+	state, err := loadPushState(s.cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = &pushState{Since: time.Now()}
+	}
+	if state.Commits, err = s.gitSync.Unpushed(); err != nil {
+		return err
+	}
+	state.Attempts++
+	state.LastError = pushErr.Error()
+	state.NextAttempt = time.Now().Add(retryDelay(state.Attempts))
+	if err := s.savePushState(state); err != nil {
+		return err
+	}
+
+	if s.retry != nil {
+		// Wake up the retry loop, which may be waiting for an earlier attempt
+		select {
+		case s.retry <- struct{}{}:
+		default:
+		}
+		return fmt.Errorf("%d commits are kept locally, retrying in %s: %w", state.Commits, time.Until(state.NextAttempt).Round(time.Second), pushErr)
+	}
+	return fmt.Errorf("%d commits are kept locally and pushed on the next sync: %w", state.Commits, pushErr)
+}
+
+// savePushState writes the unpushed state
+func (s *Service) savePushState(state *pushState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode push state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.cfg.DataDir, PushStateName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write push state: %w", err)
+	}
+	return nil
+}
+
+// pushSucceeded clears the unpushed state
+func (s *Service) pushSucceeded() error {
+	err := os.Remove(filepath.Join(s.cfg.DataDir, PushStateName))
+	if err == nil {
+		log.Println("Pushed the commits kept locally")
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove push state: %w", err)
+	}
+	return nil
+}
+
+// retryPushes pushes the local commits in the background whenever a push
+// failed, backing off while the remote can't be reached. Failed pushes
+// signal the retry channel.
+func (s *Service) retryPushes(retry <-chan struct{}, stop <-chan struct{}) {
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	for {
+		select {
+		case <-retry:
+			state, err := loadPushState(s.cfg.DataDir)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+			if state == nil {
+				continue
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(time.Until(state.NextAttempt))
+		case <-timer.C:
+			if err := s.retryPush(); err != nil {
+				log.Printf("Push failed: %v", err)
+			}
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// retryPush fetches and pushes the local commits if there are any left
+func (s *Service) retryPush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, err := loadPushState(s.cfg.DataDir)
+	if err != nil || state == nil {
+		return err
+	}
+
+	log.Printf("Retrying push of %d local commits", state.Commits)
+	if _, err := s.gitSync.Fetch(); err != nil {
+		return s.pushFailed(err)
+	}
+	if err := s.push(); err != nil {
+		return s.pushFailed(err)
+	}
+	return s.pushSucceeded()
+}
+
+// pushOnShutdown pushes the local commits before the service stops, giving
+// up after the timeout. The commits are recorded as unpushed before the push
+// starts, so that they are reported and pushed on the next start even if
+// the push doesn't finish in time.
+func (s *Service) pushOnShutdown(timeout time.Duration) {
+	s.mu.Lock()
+	s.retry = nil // Failed pushes are retried when the service starts again
+	unpushed, err := s.gitSync.Unpushed()
+	if err == nil && unpushed > 0 {
+		err = s.recordUnpushed(unpushed)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	if unpushed == 0 {
+		return
+	}
+
+	log.Printf("Pushing %d local commits", unpushed)
+	done := make(chan error, 1)
+	go func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, err := s.gitSync.Fetch(); err != nil {
+			log.Printf("Warning: Fetch failed: %v", err)
+		}
+		if err := s.push(); err != nil {
+			done <- s.pushFailed(err)
+			return
+		}
+		done <- s.pushSucceeded()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Printf("Push failed: %v", err)
+		}
+	case <-time.After(timeout):
+		log.Printf("Push didn't finish within %s, %d local commits are pushed when the service starts again", timeout, unpushed)
+	}
+}
+
+// recordUnpushed records local commits in the unpushed state without
+// counting a failed attempt
+func (s *Service) recordUnpushed(commits int) error {
+	state, err := loadPushState(s.cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = &pushState{Since: time.Now(), LastError: "the service stopped before the push finished"}
+	}
+	state.Commits = commits
+	state.NextAttempt = time.Now()
+	return s.savePushState(state)
+}
+
+// writePushStatus reports local commits that haven't been pushed yet
+func writePushStatus(cfg *config.Config, w io.Writer) error {
+	state, err := loadPushState(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		fmt.Fprintln(w, "✓ All commits are pushed")
+		return nil
+	}
+
+	fmt.Fprintf(w, "✗ %d commits not pushed since %s (%d failed attempts)\n", state.Commits, state.Since.Format("2006-01-02 15:04"), state.Attempts)
+	fmt.Fprintf(w, "  Last error: %s\n", state.LastError)
+	fmt.Fprintf(w, "  Next attempt: %s, or on the next sync\n", state.NextAttempt.Format("2006-01-02 15:04:05"))
+	return nil
+}
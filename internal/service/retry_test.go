@@ -0,0 +1,235 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+	"github.com/vivek-dodia/bookmarked-cli/internal/sync"
+)
+
+// newTestRemote creates a bare repository with one commit on main and
+// returns its path
+func newTestRemote(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "remote.git")
+	seed := filepath.Join(dir, "seed")
+
+	initOpts := git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")}
+	if _, err := git.PlainInitWithOptions(bare, &git.PlainInitOptions{InitOptions: initOpts, Bare: true}); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := git.PlainInitWithOptions(seed, &git.PlainInitOptions{InitOptions: initOpts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	author := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := w.Commit("Initial commit", &git.CommitOptions{Author: author, AllowEmptyCommits: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{bare}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Push(&git.PushOptions{RemoteName: "origin"}); err != nil {
+		t.Fatal(err)
+	}
+	return bare
+}
+
+// newTestService returns a service with a clone of the remote and no
+// browser sources
+func newTestService(t *testing.T, remote string) *Service {
+	t.Helper()
+	cfg := &config.Config{
+		RemoteURL:    "file://" + remote,
+		GitHubBranch: "main",
+		PullStrategy: config.PullRebase,
+		DataDir:      t.TempDir(),
+	}
+	gs, err := sync.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return &Service{cfg: cfg, gitSync: gs}
+}
+
+func commitFile(t *testing.T, s *Service, name, contents string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(s.gitSync.GetRepoPath(), name), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if committed, err := s.gitSync.Commit("Update " + name); err != nil || !committed {
+		t.Fatalf("Commit = %v, %v", committed, err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts := 1; attempts <= 40; attempts++ {
+		want := maxRetryDelay
+		if attempts <= 7 {
+			want = minRetryDelay << (attempts - 1)
+		}
+		for i := 0; i < 20; i++ {
+			if d := retryDelay(attempts); d < want/2 || d >= want {
+				t.Fatalf("retryDelay(%d) = %s, want [%s, %s)", attempts, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestPushOutage(t *testing.T) {
+	remote := newTestRemote(t)
+	s := newTestService(t, remote)
+
+	// Take the remote away, as if the machine were offline
+	offline := remote + ".offline"
+	if err := os.Rename(remote, offline); err != nil {
+		t.Fatal(err)
+	}
+
+	commitFile(t, s, "Bookmarks.json", "one\n")
+	err := s.push()
+	if err == nil {
+		t.Fatal("push succeeded without a remote")
+	}
+	before := time.Now()
+	if err := s.pushFailed(err); err == nil || !strings.Contains(err.Error(), "1 commits are kept locally") {
+		t.Errorf("pushFailed = %v", err)
+	}
+
+	state, err := loadPushState(s.cfg.DataDir)
+	if err != nil || state == nil {
+		t.Fatalf("loadPushState = %v, %v", state, err)
+	}
+	if state.Commits != 1 || state.Attempts != 1 || state.LastError == "" {
+		t.Errorf("push state = %+v", state)
+	}
+	if wait := state.NextAttempt.Sub(before); wait < minRetryDelay/2-time.Second || wait > minRetryDelay {
+		t.Errorf("next attempt in %s, want about %s", wait, minRetryDelay)
+	}
+	since := state.Since
+
+	// Another commit while still offline
+	commitFile(t, s, "Bookmarks.json", "two\n")
+	if err := s.retryPush(); err == nil {
+		t.Fatal("retryPush succeeded without a remote")
+	}
+	if state, err = loadPushState(s.cfg.DataDir); err != nil || state == nil {
+		t.Fatalf("loadPushState = %v, %v", state, err)
+	}
+	if state.Commits != 2 || state.Attempts != 2 || !state.Since.Equal(since) {
+		t.Errorf("push state after the second failure = %+v", state)
+	}
+
+	var status bytes.Buffer
+	if err := writePushStatus(s.cfg, &status); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(status.String(), "2 commits not pushed") {
+		t.Errorf("status = %q", status.String())
+	}
+
+	// Back online
+	if err := os.Rename(offline, remote); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.retryPush(); err != nil {
+		t.Fatalf("retryPush: %v", err)
+	}
+	if state, err := loadPushState(s.cfg.DataDir); err != nil || state != nil {
+		t.Errorf("push state after recovery = %+v, %v, want none", state, err)
+	}
+	if n, err := s.gitSync.Unpushed(); err != nil || n != 0 {
+		t.Errorf("Unpushed = %d, %v after recovery", n, err)
+	}
+
+	other := newTestService(t, remote)
+	data, err := other.gitSync.ReadFile("HEAD", "Bookmarks.json")
+	if err != nil || string(data) != "two\n" {
+		t.Errorf("Bookmarks.json on the remote = %q, %v", data, err)
+	}
+}
+
+func TestRetryPushWithoutPendingCommits(t *testing.T) {
+	s := newTestService(t, newTestRemote(t))
+	if err := s.retryPush(); err != nil {
+		t.Errorf("retryPush = %v", err)
+	}
+
+	var status bytes.Buffer
+	if err := writePushStatus(s.cfg, &status); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(status.String(), "All commits are pushed") {
+		t.Errorf("status = %q", status.String())
+	}
+}
+
+func TestPushOnShutdown(t *testing.T) {
+	remote := newTestRemote(t)
+	s := newTestService(t, remote)
+
+	commitFile(t, s, "Bookmarks.json", "one\n")
+	s.pushOnShutdown(time.Minute)
+	if n, err := s.gitSync.Unpushed(); err != nil || n != 0 {
+		t.Errorf("Unpushed = %d, %v after shutdown", n, err)
+	}
+	if state, err := loadPushState(s.cfg.DataDir); err != nil || state != nil {
+		t.Errorf("push state after shutdown = %+v, %v, want none", state, err)
+	}
+
+	// Offline, the commits are recorded for the next start
+	if err := os.Rename(remote, remote+".offline"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, s, "Bookmarks.json", "two\n")
+	s.pushOnShutdown(time.Minute)
+	state, err := loadPushState(s.cfg.DataDir)
+	if err != nil || state == nil {
+		t.Fatalf("loadPushState = %v, %v", state, err)
+	}
+	if state.Commits != 1 || state.Attempts != 1 || state.LastError == "" {
+		t.Errorf("push state after shutdown = %+v", state)
+	}
+}
+
+func TestRecordUnpushed(t *testing.T) {
+	s := newTestService(t, newTestRemote(t))
+	commitFile(t, s, "Bookmarks.json", "one\n")
+
+	// A push that doesn't finish before the service stops
+	if err := s.recordUnpushed(1); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadPushState(s.cfg.DataDir)
+	if err != nil || state == nil {
+		t.Fatalf("loadPushState = %v, %v", state, err)
+	}
+	if state.Commits != 1 || state.Attempts != 0 || state.LastError == "" {
+		t.Errorf("push state = %+v", state)
+	}
+
+	// The next start pushes the commits
+	if err := s.retryPush(); err != nil {
+		t.Fatalf("retryPush: %v", err)
+	}
+	if state, err := loadPushState(s.cfg.DataDir); err != nil || state != nil {
+		t.Errorf("push state after the push = %+v, %v, want none", state, err)
+	}
+}
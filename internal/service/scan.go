@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+	"github.com/vivek-dodia/bookmarked-cli/internal/sync"
+)
+
+// Scan reports secrets in the live bookmarks, which are redacted before
+// syncing, and in every synced version in the repository history, which
+// were already pushed. It returns an error if the history has secrets.
+func (s *Service) Scan(w io.Writer) error {
+	if err := s.setup(); err != nil {
+		return err
+	}
+
+	scanner, err := newRedactor(s.cfg, true)
+	if err != nil {
+		return err
+	}
+
+	for _, src := range s.sources {
+		live, err := src.profile.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load bookmarks: %w", err)
+		}
+		if s.filter != nil {
+			if live, _, err = s.filter.Apply(live); err != nil {
+				return err
+			}
+		}
+
+		secrets, err := scanner.Scan(live)
+		if err != nil {
+			return err
+		}
+		if len(secrets) == 0 {
+			fmt.Fprintf(w, "✓ No secrets in the live %s bookmarks\n", src.label)
+			continue
+		}
+		fmt.Fprintf(w, "Live %s bookmarks, redacted before syncing:\n", src.label)
+		for _, secret := range secrets {
+			fmt.Fprintf(w, "  - %s\n", secret)
+		}
+	}
+
+	// Report each secret once, at the oldest commit it was found in. Each
+	// blob is scanned once, only its secrets are kept.
+	type finding struct {
+		commit string
+		when   time.Time
+		path   string
+		secret bookmarks.Secret
+	}
+	var order []string
+	findings := make(map[string]finding)
+	scanned := make(map[string][]bookmarks.Secret)
+
+	err = s.gitSync.FileHistory(bookmarks.RepoFileName, func(v sync.FileVersion) error {
+		secrets, ok := scanned[v.Hash]
+		if !ok {
+			data, err := v.Read()
+			if err != nil {
+				return err
+			}
+			if data, err = s.decrypt(data); err != nil {
+				return fmt.Errorf("failed to read %s at %s: %w", v.Path, v.Commit[:7], err)
+			}
+			f, err := bookmarks.Parse(data)
+			if err != nil {
+				log.Printf("Warning: skipping %s at %s: %v", v.Path, v.Commit[:7], err)
+				scanned[v.Hash] = nil
+				return nil
+			}
+			if secrets, err = scanner.Scan(f); err != nil {
+				return err
+			}
+			scanned[v.Hash] = secrets
+		}
+
+		for _, secret := range secrets {
+			key := v.Path + "\x00" + secret.Node.URL
+			if _, seen := findings[key]; !seen {
+				order = append(order, key)
+			}
+			findings[key] = finding{commit: v.Commit, when: v.When, path: v.Path, secret: secret}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(order) == 0 {
+		fmt.Fprintln(w, "✓ No secrets in the repository history")
+		return nil
+	}
+
+	fmt.Fprintln(w, "Repository history:")
+	for _, key := range order {
+		f := findings[key]
+		fmt.Fprintf(w, "  ✗ %s %s %s: %s\n", f.commit[:7], f.when.Format("2006-01-02"), f.path, f.secret)
+	}
+	fmt.Fprintln(w, "\nThese were pushed before redaction. Revoke them, then rewrite the history")
+	fmt.Fprintln(w, "(e.g. with git filter-repo) or start a new repository.")
+
+	return fmt.Errorf("found %d secrets in the repository history", len(order))
+}
@@ -1,24 +1,36 @@
 package service
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	stdsync "sync"
 	"syscall"
 	"time"
 
 	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
 	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+	"github.com/vivek-dodia/bookmarked-cli/internal/crypt"
 	"github.com/vivek-dodia/bookmarked-cli/internal/sync"
 	"github.com/vivek-dodia/bookmarked-cli/internal/watcher"
 )
 
 type Service struct {
-	cfg          *config.Config
-	gitSync      *sync.GitSync
-	bookmarkPath string
-	watcher      *watcher.Watcher
+	cfg      *config.Config
+	gitSync  *sync.GitSync
+	sources  []source
+	filter   *bookmarks.Filter
+	redactor *bookmarks.Redactor
+	key      *crypt.Key // Encrypts synced files, nil without encryption
+	watcher  *watcher.Watcher
+	explain  bool // Print the bookmarks dropped by filters on each sync
+
+	mu      stdsync.Mutex              // Serializes syncs from the watcher and the pull timer
+	applied map[string]*bookmarks.File // Two-way sync: last bookmarks applied per repository file
+	retry   chan struct{}              // Wakes up the push retry loop, nil outside the service
 }
 
 // New creates a new Service instance
@@ -43,25 +55,18 @@ func (s *Service) Start() error {
 	log.Println("=== Bookmarked Service Starting ===")
 	log.Printf("Time: %s", time.Now().Format(time.RFC3339))
 
-	// Get bookmark path
-	bookmarkPath, err := bookmarks.GetBookmarkPath()
-	if err != nil {
-		return fmt.Errorf("failed to get bookmark path: %w", err)
+	if err := s.setup(); err != nil {
+		return err
 	}
-	s.bookmarkPath = bookmarkPath
-	log.Printf("Chrome bookmarks: %s", bookmarkPath)
-
-	// Initialize git sync
-	gitSync, err := sync.New(s.cfg)
-	if err != nil {
-		return fmt.Errorf("failed to create git sync: %w", err)
+	for _, src := range s.sources {
+		log.Printf("%s bookmarks (%s): %s", src.profile.Browser.Name, src.label, src.profile.BookmarkPath)
 	}
-	s.gitSync = gitSync
 
-	// Initialize repository (clone or open)
-	if err := s.gitSync.Initialize(); err != nil {
-		return fmt.Errorf("failed to initialize repository: %w", err)
-	}
+	// Pushes that fail, e.g. while offline, are retried in the background
+	s.retry = make(chan struct{}, 1)
+	stopRetry := make(chan struct{})
+	defer close(stopRetry)
+	go s.retryPushes(s.retry, stopRetry)
 
 	// Do initial sync
 	log.Println("Performing initial sync...")
@@ -69,86 +74,349 @@ func (s *Service) Start() error {
 		log.Printf("Initial sync failed: %v", err)
 	}
 
-	// Set up file watcher
-	w, err := watcher.New(s.cfg.DebounceMs, func() {
+	syncNow := func() {
 		if err := s.performSync(); err != nil {
 			log.Printf("Sync failed: %v", err)
 		}
-	})
+	}
+	commitNow := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		committed, err := s.commitChanges()
+		if err != nil {
+			log.Printf("Sync failed: %v", err)
+		}
+		return committed
+	}
+	pushNow := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := s.pushCommits(); err != nil {
+			log.Printf("Push failed: %v", err)
+		}
+	}
+
+	// Set up file watcher, rate-limiting the commits and pushes of bulk
+	// operations
+	batch := newBatcher(time.Duration(s.cfg.MinCommitInterval), time.Duration(s.cfg.MaxPushInterval), commitNow, pushNow)
+	w, err := watcher.New(s.cfg.DebounceMs, batch.changed)
 	if err != nil {
 		return fmt.Errorf("failed to create watcher: %w", err)
 	}
 	s.watcher = w
 
-	if err := w.Watch(bookmarkPath); err != nil {
-		return fmt.Errorf("failed to watch bookmarks: %w", err)
+	for _, src := range s.sources {
+		watch := w.Watch
+		if src.profile.Browser.Format == bookmarks.FormatFirefox {
+			// Firefox writes a new backup file rather than updating one
+			watch = w.WatchDir
+		}
+		if err := watch(src.profile.BookmarkPath); err != nil {
+			return fmt.Errorf("failed to watch bookmarks: %w", err)
+		}
 	}
 
 	log.Println("Service started successfully, watching for changes...")
 	fmt.Println("✓ Bookmarked service is running")
 	fmt.Println("Press Ctrl+C to stop")
 
+	// With two-way sync, changes from other machines are pulled
+	// periodically, and applied once the browser is closed
+	var pullTimer <-chan time.Time
+	if s.cfg.TwoWay {
+		ticker := time.NewTicker(time.Duration(s.cfg.PullIntervalSec) * time.Second)
+		defer ticker.Stop()
+		pullTimer = ticker.C
+	}
+
 	// Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
+	for waiting := true; waiting; {
+		select {
+		case <-pullTimer:
+			syncNow()
+		case <-sigChan:
+			waiting = false
+		}
+	}
 
 	log.Println("Shutting down gracefully...")
+	pending := s.watcher.Stop()
 	s.watcher.Close()
+
+	// Commit changes still waiting for the debounce period or a rate limit,
+	// then push the commits, including those waiting for max_push_interval
+	if batch.stop() || pending {
+		log.Println("Committing the pending changes")
+		commitNow()
+	}
+	s.pushOnShutdown(shutdownPushTimeout)
 	log.Println("Service stopped")
 
 	return nil
 }
 
 // SyncOnce performs a one-time sync
-func (s *Service) SyncOnce() error {
+func (s *Service) SyncOnce(opts SyncOptions) error {
 	log.Println("=== Manual Sync ===")
 
-	// Get bookmark path
-	bookmarkPath, err := bookmarks.GetBookmarkPath()
+	if opts.DryRun {
+		// Nothing is cloned or written
+		if err := s.configure(); err != nil {
+			return err
+		}
+		cloned, err := s.openLocalRepo()
+		if err != nil {
+			return err
+		}
+		return s.dryRun(opts.Explain, cloned, os.Stdout)
+	}
+
+	if err := s.setup(); err != nil {
+		return err
+	}
+
+	// Perform sync
+	s.explain = opts.Explain
+	return s.performSync()
+}
+
+// setup locates the bookmarks files and opens (or clones) the repository
+func (s *Service) setup() error {
+	if err := s.configure(); err != nil {
+		return err
+	}
+	return s.openRepo()
+}
+
+// configure locates the bookmarks files and checks the settings
+func (s *Service) configure() error {
+	sources, err := resolveSources(s.cfg)
 	if err != nil {
-		return fmt.Errorf("failed to get bookmark path: %w", err)
+		return err
 	}
-	s.bookmarkPath = bookmarkPath
+	s.sources = sources
 
-	// Initialize git sync
+	for _, output := range s.cfg.Outputs {
+		exporter, err := bookmarks.FindExporter(output)
+		if err != nil {
+			return fmt.Errorf("invalid outputs setting: %w", err)
+		}
+		if exporter.Format == bookmarks.OutputMarkdownTree && s.cfg.Encryption.Enabled() {
+			// Only the contents are encrypted, the file names are folder names
+			return fmt.Errorf("invalid outputs setting: %s can't be used with encryption, its file names would reveal the folder names", output)
+		}
+	}
+	if err := bookmarks.ValidateVolatileFields(s.cfg.Canonical.VolatileFields); err != nil {
+		return fmt.Errorf("invalid canonical.volatile_fields setting: %w", err)
+	}
+	if s.filter, err = newFilter(s.cfg); err != nil {
+		return err
+	}
+	if s.redactor, err = newRedactor(s.cfg, false); err != nil {
+		return err
+	}
+	for _, src := range s.sources {
+		if src.profile.Browser.Format == bookmarks.FormatFirefox {
+			warnFirefoxLag(src)
+		}
+	}
+	if s.cfg.TwoWay {
+		for _, src := range s.sources {
+			if !s.twoWay(src) {
+				log.Printf("Warning: two_way isn't supported for %s, its bookmarks are only synced to the repository", src.profile.Browser.Name)
+			}
+		}
+		if err := s.loadTwoWayState(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openRepo initializes git sync and opens (or clones) the repository
+func (s *Service) openRepo() error {
 	gitSync, err := sync.New(s.cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create git sync: %w", err)
 	}
 	s.gitSync = gitSync
 
-	// Initialize repository
+	// Initialize repository (clone or open)
 	if err := s.gitSync.Initialize(); err != nil {
 		return fmt.Errorf("failed to initialize repository: %w", err)
 	}
 
-	// Perform sync
-	return s.performSync()
+	return s.setupEncryption()
 }
 
-// performSync executes the sync operation
+// openLocalRepo opens the repository if it was cloned already, without
+// cloning it. It reports whether there is a clone.
+func (s *Service) openLocalRepo() (bool, error) {
+	gitSync, err := sync.New(s.cfg)
+	if err != nil {
+		return false, fmt.Errorf("failed to create git sync: %w", err)
+	}
+	s.gitSync = gitSync
+
+	cloned, err := s.gitSync.Open()
+	if err != nil || !cloned {
+		return false, err
+	}
+	return true, s.setupEncryption()
+}
+
+// performSync commits the bookmarks that changed and pushes the commits
 func (s *Service) performSync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	startTime := time.Now()
 	log.Println("--- Sync Starting ---")
 
-	// Pull latest changes first
-	if err := s.gitSync.Pull(); err != nil {
-		log.Printf("Warning: Pull failed: %v", err)
+	if _, err := s.commitChanges(); err != nil {
+		return err
 	}
+	if err := s.pushCommits(); err != nil {
+		return err
+	}
+
+	duration := time.Since(startTime)
+	log.Printf("--- Sync Complete (took %v) ---", duration)
+	return nil
+}
 
-	// Copy and format bookmarks to repo
-	if err := bookmarks.CopyToRepo(s.bookmarkPath, s.gitSync.GetRepoPath()); err != nil {
-		return fmt.Errorf("failed to copy bookmarks: %w", err)
+// commitChanges formats the bookmarks into the repository and commits them
+// locally if they changed. It reports whether there was a commit. s.mu must
+// be held.
+func (s *Service) commitChanges() (bool, error) {
+	if err := s.newEncryptionKey(); err != nil {
+		return false, err
 	}
 
-	// Commit and push
-	commitMsg := fmt.Sprintf("%s - %s", s.cfg.CommitMessage, time.Now().Format(time.RFC3339))
-	if err := s.gitSync.CommitAndPush(commitMsg); err != nil {
-		return fmt.Errorf("failed to commit and push: %w", err)
+	var synced []profileChanges
+	changed := make(map[string]bool)
+	for _, src := range s.sources {
+		changes, err := s.syncSource(src)
+		if err != nil {
+			return false, fmt.Errorf("failed to sync profile %s: %w", src.label, err)
+		}
+		synced = append(synced, profileChanges{profile: src.label, changes: changes})
+		changed[src.repoFile()] = !changes.IsEmpty()
+	}
+	if err := s.updateIndex(changed); err != nil {
+		return false, fmt.Errorf("failed to update %s: %w", IndexFileName, err)
 	}
 
-	duration := time.Since(startTime)
-	log.Printf("--- Sync Complete (took %v) ---", duration)
+	commitMsg, err := s.commitMessage(synced)
+	if err != nil {
+		return false, err
+	}
+	return s.gitSync.Commit(commitMsg)
+}
+
+// pushCommits integrates the remote changes and pushes the local commits.
+// With two-way sync the remote changes are then applied to the browsers.
+// s.mu must be held.
+func (s *Service) pushCommits() error {
+	// Fetch first so that remote commits are integrated before pushing
+	if _, err := s.gitSync.Fetch(); err != nil {
+		log.Printf("Warning: Fetch failed: %v", err)
+	}
+
+	// Commits stay in the local repository until a push succeeds
+	if err := s.push(); err != nil {
+		return s.pushFailed(err)
+	}
+	if err := s.pushSucceeded(); err != nil {
+		return err
+	}
+	if s.cfg.TwoWay {
+		if err := s.applyRemote(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// syncSource formats a profile's bookmarks into the repository and returns
+// the changes since the last synced version
+func (s *Service) syncSource(src source) (*bookmarks.ChangeSet, error) {
+	// Load the previously synced version to describe the changes
+	previous, err := s.loadRevision("HEAD", src.repoFile())
+	if err != nil {
+		log.Printf("Warning: failed to load previous bookmarks: %v", err)
+	}
+
+	// Filters apply before anything is written to the repository
+	live, dropped, err := s.loadLive(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(dropped) > 0 {
+		log.Printf("Filters left out %d bookmarks or folders of %s", len(dropped), src.label)
+	}
+	if s.explain {
+		writeDropped(os.Stdout, dropped)
+	}
+
+	// Keep changes from other machines the browser doesn't have yet
+	if s.twoWay(src) && previous != nil {
+		if live, err = s.mergeRemote(src, live, previous); err != nil {
+			return nil, err
+		}
+	}
+
+	// Leave out volatile fields so that e.g. opening a bookmark isn't a change
+	current, volatile, err := live.Canonicalize(s.cfg.Canonical.VolatileFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize bookmarks: %w", err)
+	}
+
+	targetDir := filepath.Join(s.gitSync.GetRepoPath(), src.repoDir)
+	changed, err := s.contentChanged(current, filepath.Join(targetDir, bookmarks.RepoFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	// Format bookmarks into the repo
+	rw := s.repoWriter()
+	if err := rw.Write(current, targetDir); err != nil {
+		return nil, fmt.Errorf("failed to copy bookmarks: %w", err)
+	}
+
+	// Volatile values are only updated along with a meaningful change
+	volatilePath := filepath.Join(targetDir, bookmarks.VolatileFileName)
+	if _, err := os.Stat(volatilePath); os.IsNotExist(err) {
+		changed = true
+	}
+	if s.cfg.Canonical.Separate && changed {
+		if err := rw.WriteVolatile(volatile, targetDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return bookmarks.Diff(previous, current), nil
+}
+
+// contentChanged reports whether formatted bookmarks differ from the file
+// at path
+func (s *Service) contentChanged(f *bookmarks.File, path string) (bool, error) {
+	formatted, err := f.Format()
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read synced bookmarks: %w", err)
+	}
+	if existing, err = s.decrypt(existing); err != nil {
+		return false, err
+	}
+	return !bytes.Equal(formatted, existing), nil
+}
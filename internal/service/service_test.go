@@ -0,0 +1,38 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+)
+
+func TestConfigureRejectsMarkdownTreeWithEncryption(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("LOCALAPPDATA", home)
+	userDataDir, err := bookmarks.Chrome.UserDataDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(userDataDir, bookmarks.DefaultProfile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(userDataDir, bookmarks.DefaultProfile, "Bookmarks"), []byte(`{"roots": {}, "version": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, output := range []string{"markdown-tree", "Markdown-Tree"} {
+		s := &Service{cfg: &config.Config{
+			Browser:    config.StringList{bookmarks.Chrome.ID},
+			Outputs:    config.StringList{output},
+			Encryption: config.EncryptionConfig{Passphrase: "secret"},
+		}}
+		if err := s.configure(); err == nil || !strings.Contains(err.Error(), "can't be used with encryption") {
+			t.Errorf("outputs %q with encryption: configure = %v", output, err)
+		}
+	}
+}
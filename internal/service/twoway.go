@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/bookmarks"
+)
+
+// TwoWayStateName is the file in the data directory that holds, for each
+// synced profile, the last bookmarks the browser and the repository agreed on
+const TwoWayStateName = "two-way.json"
+
+// twoWay reports whether remote changes are applied to the source's
+// bookmarks. Firefox bookmarks are only read from its backups.
+func (s *Service) twoWay(src source) bool {
+	return s.cfg.TwoWay && src.profile.Browser.Format != bookmarks.FormatFirefox
+}
+
+// loadTwoWayState reads the bookmarks last applied to each profile
+func (s *Service) loadTwoWayState() error {
+	s.applied = make(map[string]*bookmarks.File)
+
+	data, err := os.ReadFile(filepath.Join(s.cfg.DataDir, TwoWayStateName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read two-way sync state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.applied); err != nil {
+		return fmt.Errorf("failed to parse two-way sync state: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) saveTwoWayState() error {
+	data, err := json.MarshalIndent(s.applied, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode two-way sync state: %w", err)
+	}
+	if err := os.MkdirAll(s.cfg.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.cfg.DataDir, TwoWayStateName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write two-way sync state: %w", err)
+	}
+	return nil
+}
+
+// mergeRemote combines the local bookmarks with the synced ones, which may
+// contain changes from other machines the browser doesn't have yet. Without
+// an earlier state both are kept.
+func (s *Service) mergeRemote(src source, live, synced *bookmarks.File) (*bookmarks.File, error) {
+	merged, conflicts, err := bookmarks.Merge(s.applied[src.repoFile()], live, synced)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge remote changes: %w", err)
+	}
+	if len(conflicts) > 0 {
+		reportPath, err := s.reportConflicts(src.repoFile(), conflicts)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Kept the local version of %d bookmarks changed on this and another machine, see %s", len(conflicts), reportPath)
+	}
+	return merged, nil
+}
+
+// applyRemote writes the synced bookmarks to the browsers whose bookmarks
+// differ from them, keeping anything left out of the repository such as
+// filtered folders. Browsers that are running would overwrite the file, so
+// their changes wait for a later sync.
+func (s *Service) applyRemote() error {
+	changed := false
+	for _, src := range s.sources {
+		if !s.twoWay(src) {
+			continue
+		}
+
+		applied, err := s.applySource(src)
+		if err != nil {
+			return fmt.Errorf("failed to apply remote changes to %s: %w", src.label, err)
+		}
+		if applied == nil {
+			continue
+		}
+		if previous := s.applied[src.repoFile()]; previous != nil {
+			if same, err := sameBookmarks(previous, applied); err != nil || same {
+				continue
+			}
+		}
+		s.applied[src.repoFile()] = applied
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return s.saveTwoWayState()
+}
+
+// applySource brings the source's bookmarks up to date with the repository.
+// It returns the synced version once the browser has it.
+func (s *Service) applySource(src source) (*bookmarks.File, error) {
+	synced, err := s.loadRevision("HEAD", src.repoFile())
+	if err != nil || synced == nil {
+		return nil, err
+	}
+
+	// The bookmarks as they would be synced now
+	filtered, _, err := s.loadLive(src)
+	if err != nil {
+		return nil, err
+	}
+	local, _, err := filtered.Canonicalize(s.cfg.Canonical.VolatileFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize bookmarks: %w", err)
+	}
+
+	same, err := sameBookmarks(local, synced)
+	if err != nil || same {
+		return synced, err
+	}
+
+	running, err := src.profile.Browser.IsRunning()
+	if err != nil {
+		return nil, err
+	}
+	if running {
+		log.Printf("%s is running, remote changes to %s will be applied once it is closed", src.profile.Browser.Name, src.label)
+		return nil, nil
+	}
+
+	// Take the differences between the local and synced versions, leaving
+	// what the repository never sees in place
+	live, err := src.profile.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bookmarks: %w", err)
+	}
+	merged, _, err := bookmarks.Merge(local, live, synced)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := bookmarks.Diff(live, merged)
+	backupPath, err := bookmarks.WriteChromeFile(merged, src.profile.BookmarkPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Applied %d remote changes to %s bookmarks (%s), backup at %s", len(changes.Changes), src.profile.Browser.Name, src.label, backupPath)
+	return synced, nil
+}
+
+// sameBookmarks reports whether two files have the same formatted contents
+func sameBookmarks(a, b *bookmarks.File) (bool, error) {
+	aData, err := a.Format()
+	if err != nil {
+		return false, err
+	}
+	bData, err := b.Format()
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aData, bData), nil
+}
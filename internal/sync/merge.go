@@ -0,0 +1,469 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+)
+
+// ErrPushRejected is returned by Push when the remote branch has commits
+// that aren't in the local branch
+var ErrPushRejected = errors.New("push rejected, the remote has new commits")
+
+// FileConflict is a file changed on both sides of a merge. The contents are
+// nil where the file doesn't exist.
+type FileConflict struct {
+	Path   string // Slash separated path relative to the repository root
+	Base   []byte
+	Ours   []byte // The local version
+	Theirs []byte // The remote version
+}
+
+// ResolveFunc writes the resolved version of conflicting files into the
+// worktree at repoPath. Conflicting files contain the remote version when it
+// is called.
+type ResolveFunc func(repoPath string, conflicts []FileConflict) error
+
+// Fetch downloads the remote branch. It reports false if the branch doesn't
+// exist on the remote yet.
+func (gs *GitSync) Fetch() (bool, error) {
+	if gs.repo == nil {
+		return false, fmt.Errorf("repository not initialized")
+	}
+
+	auth, err := gs.auth()
+	if err != nil {
+		return false, err
+	}
+
+	refSpec := gitconfig.RefSpec(fmt.Sprintf("+%s:%s", gs.branchRef(), gs.remoteRef()))
+	err = gs.repo.Fetch(&git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []gitconfig.RefSpec{refSpec},
+		Auth:       auth,
+	})
+
+	var noMatch git.NoMatchingRefSpecError
+	switch {
+	case err == nil, errors.Is(err, git.NoErrAlreadyUpToDate):
+		return true, nil
+	case errors.As(err, &noMatch), errors.Is(err, transport.ErrEmptyRemoteRepository):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to fetch: %w", err)
+	}
+}
+
+// remoteRef returns the remote tracking reference of the configured branch
+func (gs *GitSync) remoteRef() plumbing.ReferenceName {
+	return plumbing.NewRemoteReferenceName(git.DefaultRemoteName, gs.cfg.GitHubBranch)
+}
+
+// RemoteRevision returns the revision of the fetched remote branch, for
+// ReadFile
+func (gs *GitSync) RemoteRevision() string {
+	return gs.remoteRef().String()
+}
+
+// Integrate brings the fetched remote commits into the local branch. If
+// there are no local commits the remote doesn't have the branch is
+// fast-forwarded, otherwise the local commits are rebased onto the remote
+// branch or merged with it, depending on pull_strategy. Files changed on both
+// sides are passed to resolve. It reports whether the local branch changed.
+// If integrating fails the local branch is left where it was.
+func (gs *GitSync) Integrate(resolve ResolveFunc) (bool, error) {
+	remoteRef, err := gs.repo.Reference(gs.remoteRef(), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get remote branch: %w", err)
+	}
+	head, err := gs.repo.Head()
+	if err != nil {
+		return false, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	if head.Hash() == remoteRef.Hash() {
+		return false, nil
+	}
+
+	local, err := gs.repo.CommitObject(head.Hash())
+	if err != nil {
+		return false, fmt.Errorf("failed to get commit %s: %w", head.Hash(), err)
+	}
+	remote, err := gs.repo.CommitObject(remoteRef.Hash())
+	if err != nil {
+		return false, fmt.Errorf("failed to get commit %s: %w", remoteRef.Hash(), err)
+	}
+
+	bases, err := local.MergeBase(remote)
+	if err != nil {
+		return false, fmt.Errorf("failed to find the common ancestor: %w", err)
+	}
+	var base *object.Commit
+	if len(bases) > 0 {
+		base = bases[0]
+	}
+
+	switch {
+	case base != nil && base.Hash == remote.Hash:
+		return false, nil // only local commits to push
+	case base != nil && base.Hash == local.Hash:
+		log.Printf("Fast-forwarding to %s", remote.Hash)
+		return true, gs.reset(remote.Hash)
+	}
+
+	// Rebasing and merging move the branch to the remote commit first, so
+	// the local commits must be put back if they fail
+	if err := gs.integrateDiverged(base, local, remote, resolve); err != nil {
+		if resetErr := gs.reset(local.Hash); resetErr != nil {
+			return false, fmt.Errorf("%w, and failed to restore the local branch at %s: %v", err, local.Hash, resetErr)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// integrateDiverged rebases the local commits onto the remote branch or
+// merges them, depending on pull_strategy
+func (gs *GitSync) integrateDiverged(base, local, remote *object.Commit, resolve ResolveFunc) error {
+	if gs.cfg.PullStrategy != config.PullMerge {
+		log.Println("Local and remote branches have diverged, rebasing local commits")
+		commits, ok, err := gs.localCommits(local, base)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return gs.rebase(commits, remote, resolve)
+		}
+		log.Println("Local history contains merges, merging instead of rebasing")
+	} else {
+		log.Println("Local and remote branches have diverged, merging")
+	}
+	return gs.merge(base, local, remote, resolve)
+}
+
+// Ahead reports whether the local branch has commits the remote branch
+// doesn't have, as of the last fetch
+func (gs *GitSync) Ahead() (bool, error) {
+	head, err := gs.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	remoteRef, err := gs.repo.Reference(gs.remoteRef(), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get remote branch: %w", err)
+	}
+	return head.Hash() != remoteRef.Hash(), nil
+}
+
+// Unpushed counts the local commits the remote branch doesn't have, as of
+// the last fetch
+func (gs *GitSync) Unpushed() (int, error) {
+	head, err := gs.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	local, err := gs.repo.CommitObject(head.Hash())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get local commit: %w", err)
+	}
+
+	// Everything before the merge base is on the remote
+	var pushed []plumbing.Hash
+	remoteRef, err := gs.repo.Reference(gs.remoteRef(), true)
+	switch {
+	case errors.Is(err, plumbing.ErrReferenceNotFound):
+	case err != nil:
+		return 0, fmt.Errorf("failed to get remote branch: %w", err)
+	default:
+		remote, err := gs.repo.CommitObject(remoteRef.Hash())
+		if err != nil {
+			return 0, fmt.Errorf("failed to get remote commit: %w", err)
+		}
+		bases, err := local.MergeBase(remote)
+		if err != nil {
+			return 0, fmt.Errorf("failed to find merge base: %w", err)
+		}
+		for _, base := range bases {
+			pushed = append(pushed, base.Hash)
+		}
+	}
+
+	count := 0
+	err = object.NewCommitPreorderIter(local, nil, pushed).ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count local commits: %w", err)
+	}
+	return count, nil
+}
+
+// localCommits returns the commits after base up to local, oldest first. It
+// reports false if they can't be rebased because they include merges.
+func (gs *GitSync) localCommits(local, base *object.Commit) ([]*object.Commit, bool, error) {
+	var commits []*object.Commit
+	for c := local; base == nil || c.Hash != base.Hash; {
+		if c.NumParents() > 1 {
+			return nil, false, nil
+		}
+		commits = append([]*object.Commit{c}, commits...)
+		if c.NumParents() == 0 {
+			if base != nil {
+				return nil, false, nil
+			}
+			break
+		}
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get parent of %s: %w", c.Hash, err)
+		}
+		c = parent
+	}
+	return commits, true, nil
+}
+
+// rebase replays the local commits onto the remote branch
+func (gs *GitSync) rebase(commits []*object.Commit, onto *object.Commit, resolve ResolveFunc) error {
+	tip := onto
+	for _, c := range commits {
+		var parent *object.Commit
+		if c.NumParents() > 0 {
+			var err error
+			if parent, err = c.Parent(0); err != nil {
+				return fmt.Errorf("failed to get parent of %s: %w", c.Hash, err)
+			}
+		}
+
+		if err := gs.mergeTrees(parent, c, tip, resolve); err != nil {
+			return err
+		}
+		hash, err := gs.commitWorktree(c.Message, &c.Author, nil)
+		if err != nil {
+			return err
+		}
+		if hash.IsZero() {
+			continue // already in the remote branch
+		}
+		if tip, err = gs.repo.CommitObject(hash); err != nil {
+			return fmt.Errorf("failed to get commit %s: %w", hash, err)
+		}
+	}
+
+	log.Printf("Rebased %d commits onto %s", len(commits), onto.Hash)
+	return gs.reset(tip.Hash)
+}
+
+// merge creates a merge commit of the local and remote branches
+func (gs *GitSync) merge(base, local, remote *object.Commit, resolve ResolveFunc) error {
+	if err := gs.mergeTrees(base, local, remote, resolve); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Merge remote changes into %s", gs.cfg.GitHubBranch)
+	_, err := gs.commitWorktree(message, nil, []plumbing.Hash{local.Hash, remote.Hash})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Merged %s", remote.Hash)
+	return nil
+}
+
+// mergeTrees checks out theirs and applies the changes from base to ours on
+// top of it. Files changed on both sides are passed to resolve.
+func (gs *GitSync) mergeTrees(base, ours, theirs *object.Commit, resolve ResolveFunc) error {
+	if err := gs.reset(theirs.Hash); err != nil {
+		return err
+	}
+
+	baseFiles, err := commitFiles(base)
+	if err != nil {
+		return err
+	}
+	ourFiles, err := commitFiles(ours)
+	if err != nil {
+		return err
+	}
+	theirFiles, err := commitFiles(theirs)
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[string]bool)
+	for path := range ourFiles {
+		paths[path] = true
+	}
+	for path := range baseFiles {
+		paths[path] = true
+	}
+
+	var conflicts []FileConflict
+	for path := range paths {
+		b, o, t := baseFiles[path], ourFiles[path], theirFiles[path]
+		if sameFile(o, t) || sameFile(o, b) {
+			continue // unchanged locally, the worktree has the remote version
+		}
+		if !sameFile(t, b) {
+			conflicts = append(conflicts, FileConflict{Path: path})
+			continue
+		}
+		if err := gs.writeWorktreeFile(path, o); err != nil {
+			return err
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Path < conflicts[j].Path })
+	for i := range conflicts {
+		path := conflicts[i].Path
+		if conflicts[i].Base, err = fileContents(baseFiles[path]); err != nil {
+			return err
+		}
+		if conflicts[i].Ours, err = fileContents(ourFiles[path]); err != nil {
+			return err
+		}
+		if conflicts[i].Theirs, err = fileContents(theirFiles[path]); err != nil {
+			return err
+		}
+	}
+	return resolve(gs.repoPath, conflicts)
+}
+
+// commitWorktree commits every change in the worktree. It returns a zero
+// hash without committing if nothing changed and parents aren't given.
+func (gs *GitSync) commitWorktree(message string, author *object.Signature, parents []plumbing.Hash) (plumbing.Hash, error) {
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	if err := w.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to add files: %w", err)
+	}
+	if parents == nil {
+		status, err := w.Status()
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to get status: %w", err)
+		}
+		if status.IsClean() {
+			return plumbing.ZeroHash, nil
+		}
+	}
+
+	if author == nil {
+		author = signature()
+	}
+	hash, err := w.Commit(message, &git.CommitOptions{
+		Author:    author,
+		Committer: signature(),
+		Parents:   parents,
+	})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to commit: %w", err)
+	}
+	return hash, nil
+}
+
+// reset points the branch at hash and checks it out, discarding changes in
+// the worktree
+func (gs *GitSync) reset(hash plumbing.Hash) error {
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset}); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", hash, err)
+	}
+	return nil
+}
+
+// writeWorktreeFile writes the file's contents to the worktree, or removes
+// the path if file is nil
+func (gs *GitSync) writeWorktreeFile(path string, file *object.File) error {
+	fullPath := filepath.Join(gs.repoPath, filepath.FromSlash(path))
+	if file == nil {
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+
+	data, err := fileContents(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// commitFiles maps the paths of the files in a commit to their blobs. A nil
+// commit has no files.
+func commitFiles(c *object.Commit) (map[string]*object.File, error) {
+	files := make(map[string]*object.File)
+	if c == nil {
+		return files, nil
+	}
+
+	iter, err := c.Files()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of %s: %w", c.Hash, err)
+	}
+	err = iter.ForEach(func(f *object.File) error {
+		files[f.Name] = f
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of %s: %w", c.Hash, err)
+	}
+	return files, nil
+}
+
+func fileContents(f *object.File) ([]byte, error) {
+	if f == nil {
+		return nil, nil
+	}
+	text, err := f.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return []byte(text), nil
+}
+
+// sameFile reports whether two versions of a file have the same contents,
+// treating a missing file as different from any existing one
+func sameFile(a, b *object.File) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Hash == b.Hash
+}
@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+)
+
+// diverge gives two clones of the same remote their own commits and pushes
+// the remote one, so that local has to integrate it
+func diverge(t *testing.T, strategy, localFile, localContents, remoteFile, remoteContents string) (local *GitSync, remote string) {
+	t.Helper()
+	remote = newRemote(t)
+	local = newClone(t, remote, "main", strategy)
+	other := newClone(t, remote, "main", strategy)
+
+	writeFile(t, other, remoteFile, remoteContents)
+	commit(t, other, "Remote change")
+	if err := other.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	writeFile(t, local, localFile, localContents)
+	commit(t, local, "Local change")
+	if err := local.Push(); !errors.Is(err, ErrPushRejected) {
+		t.Fatalf("Push of a diverged branch = %v, want ErrPushRejected", err)
+	}
+	if _, err := local.Fetch(); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	return local, remote
+}
+
+func noConflicts(t *testing.T) ResolveFunc {
+	return func(string, []FileConflict) error {
+		t.Error("resolve called without conflicts")
+		return nil
+	}
+}
+
+func readWorktree(t *testing.T, gs *GitSync, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(gs.GetRepoPath(), path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestIntegrateFastForward(t *testing.T) {
+	remote := newRemote(t)
+	local := newClone(t, remote, "main", config.PullRebase)
+	other := newClone(t, remote, "main", config.PullRebase)
+
+	writeFile(t, other, "Bookmarks.json", "remote\n")
+	commit(t, other, "Remote change")
+	if err := other.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := local.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := local.Integrate(noConflicts(t))
+	if err != nil || !changed {
+		t.Fatalf("Integrate = %v, %v, want true", changed, err)
+	}
+	if got := readWorktree(t, local, "Bookmarks.json"); got != "remote\n" {
+		t.Errorf("Bookmarks.json = %q after fast-forward", got)
+	}
+	if ahead, _ := local.Ahead(); ahead {
+		t.Error("fast-forwarded branch is ahead of the remote")
+	}
+}
+
+func TestIntegrateDiverged(t *testing.T) {
+	for _, strategy := range []string{config.PullRebase, config.PullMerge} {
+		t.Run(strategy, func(t *testing.T) {
+			local, remote := diverge(t, strategy, "laptop/Bookmarks.json", "local\n", "desktop/Bookmarks.json", "remote\n")
+
+			changed, err := local.Integrate(noConflicts(t))
+			if err != nil || !changed {
+				t.Fatalf("Integrate = %v, %v, want true", changed, err)
+			}
+			if err := local.Push(); err != nil {
+				t.Fatalf("Push after Integrate: %v", err)
+			}
+
+			if got := remoteFile(t, remote, "main", "laptop/Bookmarks.json"); got != "local\n" {
+				t.Errorf("local file on the remote = %q", got)
+			}
+			if got := remoteFile(t, remote, "main", "desktop/Bookmarks.json"); got != "remote\n" {
+				t.Errorf("remote file on the remote = %q", got)
+			}
+		})
+	}
+}
+
+func TestIntegrateResolvesConflicts(t *testing.T) {
+	for _, strategy := range []string{config.PullRebase, config.PullMerge} {
+		t.Run(strategy, func(t *testing.T) {
+			local, remote := diverge(t, strategy, "Bookmarks.json", "local\n", "Bookmarks.json", "remote\n")
+
+			var got []FileConflict
+			changed, err := local.Integrate(func(repoPath string, conflicts []FileConflict) error {
+				got = conflicts
+				return os.WriteFile(filepath.Join(repoPath, "Bookmarks.json"), []byte("merged\n"), 0644)
+			})
+			if err != nil || !changed {
+				t.Fatalf("Integrate = %v, %v, want true", changed, err)
+			}
+
+			if len(got) != 1 {
+				t.Fatalf("got %d conflicts, want 1", len(got))
+			}
+			c := got[0]
+			if c.Path != "Bookmarks.json" || c.Base != nil || string(c.Ours) != "local\n" || string(c.Theirs) != "remote\n" {
+				t.Errorf("conflict = %s base %q ours %q theirs %q", c.Path, c.Base, c.Ours, c.Theirs)
+			}
+
+			if err := local.Push(); err != nil {
+				t.Fatalf("Push after Integrate: %v", err)
+			}
+			if got := remoteFile(t, remote, "main", "Bookmarks.json"); got != "merged\n" {
+				t.Errorf("Bookmarks.json on the remote = %q, want the resolved version", got)
+			}
+		})
+	}
+}
+
+func TestIntegrateFailureKeepsLocalCommits(t *testing.T) {
+	for _, strategy := range []string{config.PullRebase, config.PullMerge} {
+		t.Run(strategy, func(t *testing.T) {
+			local, _ := diverge(t, strategy, "Bookmarks.json", "local\n", "Bookmarks.json", "remote\n")
+			head, err := local.repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resolveErr := errors.New("resolve failed")
+			changed, err := local.Integrate(func(string, []FileConflict) error { return resolveErr })
+			if !errors.Is(err, resolveErr) || changed {
+				t.Fatalf("Integrate = %v, %v, want the resolve error", changed, err)
+			}
+
+			after, err := local.repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Hash() != head.Hash() {
+				t.Errorf("HEAD moved from %s to %s", head.Hash(), after.Hash())
+			}
+			if got := readWorktree(t, local, "Bookmarks.json"); got != "local\n" {
+				t.Errorf("Bookmarks.json = %q, want the local version", got)
+			}
+			if n, err := local.Unpushed(); err != nil || n != 1 {
+				t.Errorf("Unpushed = %d, %v, want 1", n, err)
+			}
+		})
+	}
+}
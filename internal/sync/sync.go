@@ -1,17 +1,29 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+	"golang.org/x/crypto/ssh"
 )
 
+// ErrFileNotFound is returned by ReadFile when the file doesn't exist at the
+// requested revision
+var ErrFileNotFound = object.ErrFileNotFound
+
 type GitSync struct {
 	cfg      *config.Config
 	repoPath string
@@ -20,44 +32,71 @@ type GitSync struct {
 
 // New creates a new GitSync instance
 func New(cfg *config.Config) (*GitSync, error) {
-	repoPath, err := config.GetRepoPath()
-	if err != nil {
-		return nil, err
-	}
-
 	return &GitSync{
 		cfg:      cfg,
-		repoPath: repoPath,
+		repoPath: cfg.RepoPath(),
 	}, nil
 }
 
+// Open opens the local repository without cloning it. It reports false if
+// there is no clone yet.
+func (gs *GitSync) Open() (bool, error) {
+	if _, err := os.Stat(gs.repoPath); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	repo, err := git.PlainOpen(gs.repoPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open repository: %w", err)
+	}
+	gs.repo = repo
+	if err := gs.checkBranch(); err != nil {
+		return false, err
+	}
+	log.Println("Opened existing repository")
+	return true, nil
+}
+
 // Initialize clones the repository or opens it if it already exists
 func (gs *GitSync) Initialize() error {
-	// Check if repo already exists
-	if _, err := os.Stat(gs.repoPath); err == nil {
-		// Repo exists, open it
-		repo, err := git.PlainOpen(gs.repoPath)
-		if err != nil {
-			return fmt.Errorf("failed to open repository: %w", err)
-		}
-		gs.repo = repo
-		log.Println("Opened existing repository")
-		return nil
+	if opened, err := gs.Open(); opened || err != nil {
+		return err
 	}
 
 	// Clone the repository
-	log.Printf("Cloning repository: %s", gs.cfg.GitHubRepo)
+	repoURL := gs.cfg.RepoURL()
+	log.Printf("Cloning repository: %s (branch %s)", displayURL(repoURL), gs.cfg.GitHubBranch)
+
+	auth, err := gs.auth()
+	if err != nil {
+		return err
+	}
 
-	repoURL := fmt.Sprintf("https://github.com/%s.git", gs.cfg.GitHubRepo)
+	cloneOpts := &git.CloneOptions{
+		URL:           repoURL,
+		Auth:          auth,
+		ReferenceName: gs.branchRef(),
+		Progress:      os.Stdout,
+	}
 
-	repo, err := git.PlainClone(gs.repoPath, false, &git.CloneOptions{
-		URL: repoURL,
-		Auth: &http.BasicAuth{
-			Username: "git", // can be anything
-			Password: gs.cfg.GitHubToken,
-		},
-		Progress: os.Stdout,
-	})
+	repo, err := git.PlainClone(gs.repoPath, false, cloneOpts)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// The branch doesn't exist on the remote yet, clone the default
+		// branch and create it locally. The first push creates it remotely.
+		log.Printf("Branch %s not found on remote, creating it", gs.cfg.GitHubBranch)
+		cloneOpts.ReferenceName = ""
+		repo, err = git.PlainClone(gs.repoPath, false, cloneOpts)
+		if err == nil {
+			gs.repo = repo
+			err = gs.createBranch()
+		}
+	}
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		// Nothing was pushed yet, start the history locally. The first push
+		// creates the branch remotely.
+		log.Printf("Remote repository is empty, creating branch %s", gs.cfg.GitHubBranch)
+		repo, err = gs.initRepo(repoURL)
+	}
 
 	if err != nil {
 		return fmt.Errorf("failed to clone repository: %w", err)
@@ -68,57 +107,226 @@ func (gs *GitSync) Initialize() error {
 	return nil
 }
 
-// CommitAndPush commits changes and pushes to remote
-func (gs *GitSync) CommitAndPush(message string) error {
+// initRepo creates an empty local repository with the configured branch
+// checked out, tracking the branch of the same name on origin
+func (gs *GitSync) initRepo(repoURL string) (*git.Repository, error) {
+	repo, err := git.PlainInitWithOptions(gs.repoPath, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: gs.branchRef()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{repoURL},
+	})
+	if err == nil {
+		err = repo.CreateBranch(&gitconfig.Branch{
+			Name:   gs.cfg.GitHubBranch,
+			Remote: git.DefaultRemoteName,
+			Merge:  gs.branchRef(),
+		})
+	}
+	if err != nil {
+		// Don't leave a repository without a remote behind for Open
+		os.RemoveAll(gs.repoPath)
+		return nil, fmt.Errorf("failed to configure remote: %w", err)
+	}
+	return repo, nil
+}
+
+// auth returns the authentication method for the configured remote
+func (gs *GitSync) auth() (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(gs.cfg.RepoURL())
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote URL: %w", err)
+	}
+
+	switch ep.Protocol {
+	case "http", "https":
+		token, err := gs.cfg.Token()
+		if err != nil {
+			return nil, err
+		}
+		return &http.BasicAuth{
+			Username: "git", // can be anything
+			Password: token,
+		}, nil
+	case "ssh":
+		return gs.sshAuth(ep)
+	default:
+		// file:// needs no auth
+		return nil, nil
+	}
+}
+
+// sshAuth builds public key authentication from a key file or the ssh-agent
+// and verifies the server against known_hosts
+func (gs *GitSync) sshAuth(ep *transport.Endpoint) (transport.AuthMethod, error) {
+	a := gs.cfg.Auth
+
+	user := ep.User
+	if user == "" {
+		user = a.SSHUser
+	}
+	if user == "" {
+		user = "git"
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !a.InsecureIgnoreHostKey {
+		var files []string
+		if a.KnownHosts != "" {
+			files = append(files, a.KnownHosts)
+		}
+		cb, err := gitssh.NewKnownHostsCallback(files...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+		}
+		hostKeyCallback = cb
+	}
+
+	if a.SSHKey != "" {
+		auth, err := gitssh.NewPublicKeysFromFile(user, a.SSHKey, a.SSHKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH key %s: %w", a.SSHKey, err)
+		}
+		auth.HostKeyCallback = hostKeyCallback
+		return auth, nil
+	}
+
+	auth, err := gitssh.NewSSHAgentAuth(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+	auth.HostKeyCallback = hostKeyCallback
+	return auth, nil
+}
+
+// displayURL returns the remote URL with any embedded password removed
+func displayURL(rawURL string) string {
+	ep, err := transport.NewEndpoint(rawURL)
+	if err != nil || ep.Password == "" {
+		return rawURL
+	}
+	ep.Password = ""
+	return ep.String()
+}
+
+// branchRef returns the reference name of the configured branch
+func (gs *GitSync) branchRef() plumbing.ReferenceName {
+	return plumbing.NewBranchReferenceName(gs.cfg.GitHubBranch)
+}
+
+// checkBranch ensures the local clone has the configured branch checked
+// out, which has no commits yet if the remote was empty
+func (gs *GitSync) checkBranch() error {
+	head, err := gs.repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	if head.Target() != gs.branchRef() {
+		branch := head.Target().Short()
+		if head.Type() != plumbing.SymbolicReference {
+			branch = head.Name().Short() // detached
+		}
+		return fmt.Errorf("local repository %s is on branch %q but github_branch is %q; check out the branch or remove the directory to re-clone",
+			gs.repoPath, branch, gs.cfg.GitHubBranch)
+	}
+
+	return nil
+}
+
+// createBranch creates the configured branch at HEAD, checks it out and
+// sets it up to track the branch of the same name on origin
+func (gs *GitSync) createBranch() error {
+	w, err := gs.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	if err := w.Checkout(&git.CheckoutOptions{
+		Branch: gs.branchRef(),
+		Create: true,
+	}); err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", gs.cfg.GitHubBranch, err)
+	}
+
+	err = gs.repo.CreateBranch(&gitconfig.Branch{
+		Name:   gs.cfg.GitHubBranch,
+		Remote: git.DefaultRemoteName,
+		Merge:  gs.branchRef(),
+	})
+	if err != nil && !errors.Is(err, git.ErrBranchExists) {
+		return fmt.Errorf("failed to configure branch %s: %w", gs.cfg.GitHubBranch, err)
+	}
+
+	return nil
+}
+
+// Commit commits all changes in the worktree. It reports false if there was
+// nothing to commit.
+func (gs *GitSync) Commit(message string) (bool, error) {
 	if gs.repo == nil {
-		return fmt.Errorf("repository not initialized")
+		return false, fmt.Errorf("repository not initialized")
 	}
 
 	// Get the worktree
 	w, err := gs.repo.Worktree()
 	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+		return false, fmt.Errorf("failed to get worktree: %w", err)
 	}
 
 	// Check status to see if there are changes
 	status, err := w.Status()
 	if err != nil {
-		return fmt.Errorf("failed to get status: %w", err)
+		return false, fmt.Errorf("failed to get status: %w", err)
 	}
 
 	if status.IsClean() {
 		log.Println("No changes to commit")
-		return nil
+		return false, nil
 	}
 
-	// Add all changes
-	_, err = w.Add(".")
+	// Add all changes, including removed files
+	err = w.AddWithOptions(&git.AddOptions{All: true})
 	if err != nil {
-		return fmt.Errorf("failed to add files: %w", err)
+		return false, fmt.Errorf("failed to add files: %w", err)
 	}
 
 	// Commit
 	commit, err := w.Commit(message, &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  "Bookmarked",
-			Email: "bookmarked@local",
-			When:  time.Now(),
-		},
+		Author: signature(),
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to commit: %w", err)
+		return false, fmt.Errorf("failed to commit: %w", err)
 	}
 
 	log.Printf("Created commit: %s", commit.String())
+	return true, nil
+}
+
+// Push pushes the branch to the remote. It returns ErrPushRejected if the
+// remote branch has commits that need to be integrated first.
+func (gs *GitSync) Push() error {
+	if gs.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
 
-	// Push to remote
 	log.Println("Pushing to remote...")
+	auth, err := gs.auth()
+	if err != nil {
+		return err
+	}
+
+	refSpec := gitconfig.RefSpec(fmt.Sprintf("%s:%s", gs.branchRef(), gs.branchRef()))
 	err = gs.repo.Push(&git.PushOptions{
-		Auth: &http.BasicAuth{
-			Username: "git",
-			Password: gs.cfg.GitHubToken,
-		},
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   []gitconfig.RefSpec{refSpec},
+		Auth:       auth,
 	})
 
 	if err != nil {
@@ -127,6 +335,10 @@ func (gs *GitSync) CommitAndPush(message string) error {
 			log.Println("Already up to date")
 			return nil
 		}
+		// go-git doesn't have a sentinel error for this
+		if strings.Contains(err.Error(), "non-fast-forward") {
+			return ErrPushRejected
+		}
 		return fmt.Errorf("failed to push: %w", err)
 	}
 
@@ -134,34 +346,108 @@ func (gs *GitSync) CommitAndPush(message string) error {
 	return nil
 }
 
-// Pull fetches and merges changes from remote
-func (gs *GitSync) Pull() error {
+// signature identifies commits made by bookmarked
+func signature() *object.Signature {
+	return &object.Signature{
+		Name:  "Bookmarked",
+		Email: "bookmarked@local",
+		When:  time.Now(),
+	}
+}
+
+// ReadFile returns the contents of a file in the repository at the given
+// revision (anything accepted by git rev-parse that go-git supports, e.g.
+// HEAD~2, a branch name or a commit hash)
+func (gs *GitSync) ReadFile(rev, path string) ([]byte, error) {
 	if gs.repo == nil {
-		return fmt.Errorf("repository not initialized")
+		return nil, fmt.Errorf("repository not initialized")
 	}
 
-	w, err := gs.repo.Worktree()
+	hash, err := gs.repo.ResolveRevision(plumbing.Revision(rev))
+	if errors.Is(err, plumbing.ErrReferenceNotFound) && rev == plumbing.HEAD.String() {
+		return nil, ErrFileNotFound // no commits yet
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+		return nil, fmt.Errorf("failed to resolve revision %q: %w", rev, err)
 	}
 
-	err = w.Pull(&git.PullOptions{
-		Auth: &http.BasicAuth{
-			Username: "git",
-			Password: gs.cfg.GitHubToken,
-		},
-	})
+	commit, err := gs.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", hash, err)
+	}
 
+	file, err := commit.File(filepath.ToSlash(path))
 	if err != nil {
-		if err == git.NoErrAlreadyUpToDate {
-			log.Println("Already up to date")
-			return nil
-		}
-		return fmt.Errorf("failed to pull: %w", err)
+		return nil, fmt.Errorf("failed to find %s at %s: %w", path, rev, err)
 	}
 
-	log.Println("Pulled successfully")
-	return nil
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, rev, err)
+	}
+
+	return []byte(contents), nil
+}
+
+// FileVersion is a file at a commit. Versions with the same Hash have the
+// same content.
+type FileVersion struct {
+	Commit string
+	When   time.Time
+	Path   string
+	Hash   string // Blob hash
+
+	file *object.File
+}
+
+// Read returns the content of the file
+func (v FileVersion) Read() ([]byte, error) {
+	text, err := v.file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", v.Path, v.Commit, err)
+	}
+	return []byte(text), nil
+}
+
+// FileHistory calls fn for every file with the given base name in every
+// commit reachable from HEAD, newest first. The content is only read when
+// fn calls Read, so that callers can skip hashes they have already seen.
+func (gs *GitSync) FileHistory(name string, fn func(FileVersion) error) error {
+	if gs.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	head, err := gs.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	commits, err := gs.repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	return commits.ForEach(func(commit *object.Commit) error {
+		files, err := commit.Files()
+		if err != nil {
+			return fmt.Errorf("failed to list files of %s: %w", commit.Hash, err)
+		}
+		return files.ForEach(func(file *object.File) error {
+			if filepath.Base(file.Name) != name {
+				return nil
+			}
+			return fn(FileVersion{
+				Commit: commit.Hash.String(),
+				When:   commit.Author.When,
+				Path:   file.Name,
+				Hash:   file.Hash.String(),
+				file:   file,
+			})
+		})
+	})
 }
 
 // GetRepoPath returns the local repository path
@@ -0,0 +1,250 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/vivek-dodia/bookmarked-cli/internal/config"
+)
+
+// newRemote creates a bare repository with one commit on main and returns
+// its file:// URL
+func newRemote(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "remote.git")
+	seed := filepath.Join(dir, "seed")
+
+	initOpts := git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")}
+	if _, err := git.PlainInitWithOptions(bare, &git.PlainInitOptions{InitOptions: initOpts, Bare: true}); err != nil {
+		t.Fatalf("init remote: %v", err)
+	}
+	repo, err := git.PlainInitWithOptions(seed, &git.PlainInitOptions{InitOptions: initOpts})
+	if err != nil {
+		t.Fatalf("init seed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(seed, "README.md"), []byte("bookmarks\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("README.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Commit("Initial commit", &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{bare}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Push(&git.PushOptions{RemoteName: "origin"}); err != nil {
+		t.Fatalf("seed push: %v", err)
+	}
+	return "file://" + bare
+}
+
+// newClone clones the remote into its own data directory
+func newClone(t *testing.T, remoteURL, branch, strategy string) *GitSync {
+	t.Helper()
+	cfg := &config.Config{
+		RemoteURL:    remoteURL,
+		GitHubBranch: branch,
+		PullStrategy: strategy,
+		DataDir:      t.TempDir(),
+	}
+	gs, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return gs
+}
+
+func writeFile(t *testing.T, gs *GitSync, path, contents string) {
+	t.Helper()
+	full := filepath.Join(gs.GetRepoPath(), filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commit(t *testing.T, gs *GitSync, message string) {
+	t.Helper()
+	committed, err := gs.Commit(message)
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if !committed {
+		t.Fatalf("Commit %q: nothing to commit", message)
+	}
+}
+
+// remoteFile reads a file from the remote branch through a fresh clone
+func remoteFile(t *testing.T, remoteURL, branch, path string) string {
+	t.Helper()
+	gs := newClone(t, remoteURL, branch, config.PullRebase)
+	data, err := gs.ReadFile("HEAD", path)
+	if err != nil {
+		t.Fatalf("ReadFile %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestCommitAndPushToLocalRemote(t *testing.T) {
+	remote := newRemote(t)
+	gs := newClone(t, remote, "main", config.PullRebase)
+
+	if committed, err := gs.Commit("Nothing"); err != nil || committed {
+		t.Fatalf("Commit on a clean worktree = %v, %v, want false", committed, err)
+	}
+
+	writeFile(t, gs, "Bookmarks.json", "{}\n")
+	commit(t, gs, "Add bookmarks")
+	if ahead, err := gs.Ahead(); err != nil || !ahead {
+		t.Fatalf("Ahead = %v, %v, want true", ahead, err)
+	}
+	if err := gs.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	if got := remoteFile(t, remote, "main", "Bookmarks.json"); got != "{}\n" {
+		t.Errorf("remote Bookmarks.json = %q", got)
+	}
+}
+
+func TestNewBranchIsCreatedOnPush(t *testing.T) {
+	remote := newRemote(t)
+	gs := newClone(t, remote, "laptop", config.PullRebase)
+
+	writeFile(t, gs, "Bookmarks.json", "{}\n")
+	commit(t, gs, "Add bookmarks")
+	if err := gs.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	if got := remoteFile(t, remote, "laptop", "README.md"); got != "bookmarks\n" {
+		t.Errorf("branch wasn't created from the default branch, README.md = %q", got)
+	}
+}
+
+func TestOpenExistingClone(t *testing.T) {
+	remote := newRemote(t)
+	gs := newClone(t, remote, "main", config.PullRebase)
+
+	reopened, err := New(gs.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Initialize(); err != nil {
+		t.Fatalf("Initialize on an existing clone: %v", err)
+	}
+
+	other := *gs.cfg
+	other.GitHubBranch = "other"
+	wrongBranch, _ := New(&other)
+	if err := wrongBranch.Initialize(); err == nil {
+		t.Error("Initialize succeeded with a clone on another branch")
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	gs := newClone(t, newRemote(t), "main", config.PullRebase)
+	writeFile(t, gs, "Bookmarks.json", "one\n")
+	commit(t, gs, "First")
+	writeFile(t, gs, "laptop/Bookmarks.json", "one\n")
+	commit(t, gs, "Second")
+	writeFile(t, gs, "Bookmarks.json", "two\n")
+	commit(t, gs, "Third")
+
+	var versions []FileVersion
+	err := gs.FileHistory("Bookmarks.json", func(v FileVersion) error {
+		versions = append(versions, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Third: two, one; second: one, one; first: one
+	if len(versions) != 5 {
+		t.Fatalf("got %d versions, want 5", len(versions))
+	}
+	hashes := make(map[string]string)
+	for _, v := range versions {
+		data, err := v.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content, ok := hashes[v.Hash]; ok && content != string(data) {
+			t.Errorf("hash %s has contents %q and %q", v.Hash, content, data)
+		}
+		hashes[v.Hash] = string(data)
+	}
+	if len(hashes) != 2 {
+		t.Errorf("got %d hashes, want one per content", len(hashes))
+	}
+}
+
+func TestInitializeEmptyRemote(t *testing.T) {
+	bare := filepath.Join(t.TempDir(), "remote.git")
+	if _, err := git.PlainInit(bare, true); err != nil {
+		t.Fatal(err)
+	}
+	remote := "file://" + bare
+	gs := newClone(t, remote, "main", config.PullRebase)
+
+	if ahead, err := gs.Ahead(); err != nil || ahead {
+		t.Errorf("Ahead without commits = %v, %v, want false", ahead, err)
+	}
+	if n, err := gs.Unpushed(); err != nil || n != 0 {
+		t.Errorf("Unpushed without commits = %d, %v, want 0", n, err)
+	}
+	if fetched, err := gs.Fetch(); err != nil || fetched {
+		t.Errorf("Fetch from an empty remote = %v, %v, want false", fetched, err)
+	}
+
+	// Reopening before the first commit
+	reopened, err := New(gs.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Initialize(); err != nil {
+		t.Fatalf("Initialize on the empty clone: %v", err)
+	}
+
+	writeFile(t, gs, "Bookmarks.json", "{}\n")
+	commit(t, gs, "Add bookmarks")
+	if n, err := gs.Unpushed(); err != nil || n != 1 {
+		t.Errorf("Unpushed = %d, %v, want 1", n, err)
+	}
+	if _, err := gs.Integrate(func(string, []FileConflict) error { return nil }); err != nil {
+		t.Fatalf("Integrate: %v", err)
+	}
+	if err := gs.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if got := remoteFile(t, remote, "main", "Bookmarks.json"); got != "{}\n" {
+		t.Errorf("remote Bookmarks.json = %q", got)
+	}
+
+	// The next sync sees the pushed branch
+	if fetched, err := gs.Fetch(); err != nil || !fetched {
+		t.Errorf("Fetch after the first push = %v, %v, want true", fetched, err)
+	}
+	if n, err := gs.Unpushed(); err != nil || n != 0 {
+		t.Errorf("Unpushed after the first push = %d, %v, want 0", n, err)
+	}
+}
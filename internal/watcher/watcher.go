@@ -4,16 +4,22 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
 type Watcher struct {
-	watcher     *fsnotify.Watcher
-	debounceMs  int
-	onChange    func()
+	watcher       *fsnotify.Watcher
+	debounceMs    int
+	onChange      func()
 	debounceTimer *time.Timer
+
+	mu      sync.Mutex
+	files   map[string]bool
+	dirs    map[string]bool
+	started bool
 }
 
 // New creates a new file watcher with debouncing
@@ -27,54 +33,100 @@ func New(debounceMs int, onChange func()) (*Watcher, error) {
 		watcher:    watcher,
 		debounceMs: debounceMs,
 		onChange:   onChange,
+		files:      make(map[string]bool),
+		dirs:       make(map[string]bool),
 	}, nil
 }
 
-// Watch starts watching the specified file
+// Watch starts watching the specified file. It can be called several times
+// to watch more files; changes to any of them share one debounce timer.
 func (w *Watcher) Watch(filePath string) error {
 	// Watch the parent directory since Chrome does atomic writes (creates temp, then renames)
 	dir := filepath.Dir(filePath)
-	fileName := filepath.Base(filePath)
 
 	if err := w.watcher.Add(dir); err != nil {
 		return fmt.Errorf("failed to watch directory: %w", err)
 	}
 
+	w.mu.Lock()
+	w.files[filepath.Clean(filePath)] = true
+	w.mu.Unlock()
+
 	log.Printf("Watching for changes to: %s", filePath)
+	w.start()
+
+	return nil
+}
+
+// WatchDir starts watching for files written anywhere in the directory
+func (w *Watcher) WatchDir(dir string) error {
+	if err := w.watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch directory: %w", err)
+	}
+
+	w.mu.Lock()
+	w.dirs[filepath.Clean(dir)] = true
+	w.mu.Unlock()
+
+	log.Printf("Watching for changes in: %s", dir)
+	w.start()
+
+	return nil
+}
+
+// start runs the event loop once the first path is watched
+func (w *Watcher) start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.started {
+		w.started = true
+		go w.run()
+	}
+}
 
-	go func() {
-		for {
-			select {
-			case event, ok := <-w.watcher.Events:
-				if !ok {
-					return
-				}
-
-				// Only process events for our target file
-				if filepath.Base(event.Name) != fileName {
-					continue
-				}
-
-				// Handle Write and Rename events (Chrome does atomic writes via rename)
-				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
-					log.Printf("Detected change: %s", event.Op.String())
-					w.debounce()
-				}
-
-			case err, ok := <-w.watcher.Errors:
-				if !ok {
-					return
-				}
-				log.Printf("Watcher error: %v", err)
+// run processes filesystem events until the watcher is closed
+func (w *Watcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
 			}
+
+			// Only process events for our target files
+			if !w.isWatched(event.Name) {
+				continue
+			}
+
+			// Handle Write and Rename events (Chrome does atomic writes via rename)
+			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
+				log.Printf("Detected change: %s %s", event.Op.String(), event.Name)
+				w.debounce()
+			}
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watcher error: %v", err)
 		}
-	}()
+	}
+}
 
-	return nil
+// isWatched reports whether path is a watched file or in a watched directory
+func (w *Watcher) isWatched(path string) bool {
+	path = filepath.Clean(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.files[path] || w.dirs[filepath.Dir(path)]
 }
 
 // debounce delays the onChange callback to avoid excessive calls
 func (w *Watcher) debounce() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	if w.debounceTimer != nil {
 		w.debounceTimer.Stop()
 	}
@@ -85,10 +137,17 @@ func (w *Watcher) debounce() {
 	})
 }
 
+// Stop cancels a change waiting for the debounce period and reports whether
+// there was one. onChange isn't called for it.
+func (w *Watcher) Stop() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.debounceTimer != nil && w.debounceTimer.Stop()
+}
+
 // Close stops the watcher
 func (w *Watcher) Close() error {
-	if w.debounceTimer != nil {
-		w.debounceTimer.Stop()
-	}
+	w.Stop()
 	return w.watcher.Close()
 }